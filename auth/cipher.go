@@ -0,0 +1,203 @@
+package auth
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"github.com/pkg/errors"
+	"strings"
+)
+
+const (
+	encryptedPrefix = "enc:v1:"
+	dataKeySize     = 32
+)
+
+//TokenCipher encrypts stored OAuth2 tokens with envelope encryption: every value is sealed with a fresh
+//data key and the data key is sealed with a master key. Master keys are identified by ID, the primary one
+//encrypts new values while the rest are kept to decrypt values written before key rotation.
+//Nil TokenCipher stores tokens in plain text
+type TokenCipher struct {
+	primary string
+	keys    map[string]cipher.AEAD
+}
+
+//NewTokenCipher accepts keys as '<key ID>:<base64 encoded 32 byte key>', the first key is primary
+func NewTokenCipher(keys []string) (*TokenCipher, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("at least one key must be specified")
+	}
+
+	c := &TokenCipher{
+		keys: make(map[string]cipher.AEAD),
+	}
+
+	for i, k := range keys {
+		parts := strings.SplitN(k, ":", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, errors.Errorf("key #%d must be formatted as '<key ID>:<base64 key>'", i+1)
+		}
+		ID := parts[0]
+
+		if _, ok := c.keys[ID]; ok {
+			return nil, errors.Errorf("duplicate key ID '%s'", ID)
+		}
+
+		raw, err := base64.StdEncoding.DecodeString(parts[1])
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot decode key '%s'", ID)
+		}
+		if len(raw) != 32 {
+			return nil, errors.Errorf("key '%s' must be 32 bytes long", ID)
+		}
+
+		aead, err := newAEAD(raw)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot init key '%s'", ID)
+		}
+
+		c.keys[ID] = aead
+		if i == 0 {
+			c.primary = ID
+		}
+	}
+
+	return c, nil
+}
+
+//Encrypt seals access and refresh tokens, owner is authenticated with them so values cannot be swapped between users
+func (c *TokenCipher) Encrypt(t OAuth2Token, owner string) (OAuth2Token, error) {
+	if c == nil {
+		return t, nil
+	}
+
+	var err error
+	if t.AccessToken, err = c.encrypt(t.AccessToken, owner); err != nil {
+		return OAuth2Token{}, errors.Wrap(err, "cannot encrypt access token")
+	}
+	if t.RefreshToken, err = c.encrypt(t.RefreshToken, owner); err != nil {
+		return OAuth2Token{}, errors.Wrap(err, "cannot encrypt refresh token")
+	}
+	return t, nil
+}
+
+//Decrypt opens values sealed by Encrypt, plain text values written before encryption was enabled are returned as is
+func (c *TokenCipher) Decrypt(t OAuth2Token, owner string) (OAuth2Token, error) {
+	var err error
+	if t.AccessToken, err = c.decrypt(t.AccessToken, owner); err != nil {
+		return OAuth2Token{}, errors.Wrap(err, "cannot decrypt access token")
+	}
+	if t.RefreshToken, err = c.decrypt(t.RefreshToken, owner); err != nil {
+		return OAuth2Token{}, errors.Wrap(err, "cannot decrypt refresh token")
+	}
+	return t, nil
+}
+
+//encrypt produces 'enc:v1:<key ID>:<sealed data key>:<sealed value>'
+func (c *TokenCipher) encrypt(value, owner string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+
+	dataKey := make([]byte, dataKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", errors.Wrap(err, "cannot generate data key")
+	}
+
+	sealedKey, err := seal(c.keys[c.primary], dataKey, []byte(c.primary))
+	if err != nil {
+		return "", errors.Wrap(err, "cannot seal data key")
+	}
+
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+
+	sealedValue, err := seal(aead, []byte(value), []byte(owner))
+	if err != nil {
+		return "", errors.Wrap(err, "cannot seal value")
+	}
+
+	return encryptedPrefix + c.primary + ":" +
+		base64.RawURLEncoding.EncodeToString(sealedKey) + ":" +
+		base64.RawURLEncoding.EncodeToString(sealedValue), nil
+}
+
+func (c *TokenCipher) decrypt(value, owner string) (string, error) {
+	if !strings.HasPrefix(value, encryptedPrefix) {
+		return value, nil
+	}
+
+	if c == nil {
+		return "", errors.New("value is encrypted, but no keys are configured")
+	}
+
+	parts := strings.Split(strings.TrimPrefix(value, encryptedPrefix), ":")
+	if len(parts) != 3 {
+		return "", errors.New("malformed encrypted value")
+	}
+
+	keyID := parts[0]
+	master, ok := c.keys[keyID]
+	if !ok {
+		return "", errors.Errorf("unknown key ID '%s'", keyID)
+	}
+
+	sealedKey, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", errors.Wrap(err, "cannot decode data key")
+	}
+
+	sealedValue, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", errors.Wrap(err, "cannot decode value")
+	}
+
+	dataKey, err := open(master, sealedKey, []byte(keyID))
+	if err != nil {
+		return "", errors.Wrap(err, "cannot open data key")
+	}
+
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+
+	plain, err := open(aead, sealedValue, []byte(owner))
+	if err != nil {
+		return "", errors.Wrap(err, "cannot open value")
+	}
+
+	return string(plain), nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot create cipher")
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot create gcm")
+	}
+	return aead, nil
+}
+
+//seal prepends random nonce to the sealed data
+func seal(aead cipher.AEAD, plain, additional []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, errors.Wrap(err, "cannot generate nonce")
+	}
+	return aead.Seal(nonce, nonce, plain, additional), nil
+}
+
+func open(aead cipher.AEAD, sealed, additional []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("sealed data is too short")
+	}
+	nonce, data := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, data, additional)
+}
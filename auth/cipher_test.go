@@ -0,0 +1,90 @@
+package auth
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+var (
+	oldKey = "old:" + base64.StdEncoding.EncodeToString([]byte("0123456789abcdef0123456789abcdef"))
+	newKey = "new:" + base64.StdEncoding.EncodeToString([]byte("fedcba9876543210fedcba9876543210"))
+)
+
+func TestEncryptDecrypt(t *testing.T) {
+	c, err := NewTokenCipher([]string{oldKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token := OAuth2Token{AccessToken: "access", RefreshToken: "refresh"}
+
+	enc, err := c.Encrypt(token, "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(enc.AccessToken, encryptedPrefix+"old:") || strings.Contains(enc.RefreshToken, "refresh") {
+		t.Fatalf("tokens are not encrypted: %+v", enc)
+	}
+
+	dec, err := c.Decrypt(enc, "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dec != token {
+		t.Fatalf("expected %+v, got %+v", token, dec)
+	}
+
+	if _, err = c.Decrypt(enc, "another_user"); err == nil {
+		t.Fatal("token of another user must not be decrypted")
+	}
+
+	plain, err := c.Decrypt(token, "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plain != token {
+		t.Fatalf("plain text token must be returned as is, got %+v", plain)
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	old, err := NewTokenCipher([]string{oldKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	enc, err := old.Encrypt(OAuth2Token{AccessToken: "access"}, "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rotated, err := NewTokenCipher([]string{newKey, oldKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dec, err := rotated.Decrypt(enc, "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dec.AccessToken != "access" {
+		t.Fatalf("expected 'access', got '%s'", dec.AccessToken)
+	}
+
+	reenc, err := rotated.Encrypt(dec, "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(reenc.AccessToken, encryptedPrefix+"new:") {
+		t.Fatalf("token must be encrypted with primary key: %s", reenc.AccessToken)
+	}
+
+	withoutOld, err := NewTokenCipher([]string{newKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = withoutOld.Decrypt(enc, "user"); err == nil {
+		t.Fatal("token encrypted with unknown key must not be decrypted")
+	}
+}
@@ -0,0 +1,37 @@
+package bot
+
+import (
+	context2 "context"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	log "github.com/sirupsen/logrus"
+	"strings"
+)
+
+//handleCallback processes inline keyboard button presses
+func (t *Telegram) handleCallback(q *tgbotapi.CallbackQuery) {
+	user, err := t.userService.FindUserByTelegramID(context2.Background(), int64(q.From.ID))
+	if err != nil {
+		log.WithError(err).Error("failed to get user")
+		t.answerCallback(q, "Internal error. Please try again later")
+		return
+	}
+
+	switch {
+	case strings.HasPrefix(q.Data, deleteCallbackPrefix):
+		t.deleteEpisode(user, q, strings.TrimPrefix(q.Data, deleteCallbackPrefix))
+	case strings.HasPrefix(q.Data, feedCallbackPrefix):
+		t.chooseFeed(user, q, strings.TrimPrefix(q.Data, feedCallbackPrefix))
+	case strings.HasPrefix(q.Data, listCallbackPrefix):
+		t.turnEpisodesPage(user, q, strings.TrimPrefix(q.Data, listCallbackPrefix))
+	case strings.HasPrefix(q.Data, settingsCallbackPrefix):
+		t.changeSetting(user, q, strings.TrimPrefix(q.Data, settingsCallbackPrefix))
+	default:
+		t.answerCallback(q, "")
+	}
+}
+
+func (t *Telegram) answerCallback(q *tgbotapi.CallbackQuery, text string) {
+	if _, err := t.api.AnswerCallbackQuery(tgbotapi.NewCallback(q.ID, text)); err != nil {
+		log.WithError(err).Error("failed to answer callback query")
+	}
+}
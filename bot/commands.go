@@ -0,0 +1,111 @@
+package bot
+
+import (
+	"bytes"
+	context2 "context"
+	"fmt"
+	"github.com/htim/youpod"
+	"github.com/htim/youpod/core"
+	log "github.com/sirupsen/logrus"
+	"strconv"
+	"strings"
+)
+
+//subscribe expands the playlist in background as it may take a while.
+//Optional second argument is the name of the feed new videos go to
+func (t *Telegram) subscribe(user core.User, chatID int64, args string) {
+	fields := strings.Fields(args)
+	if len(fields) == 0 {
+		t.Send(chatID, "Usage: /subscribe <channel, playlist or podcast feed link> [feed]")
+		return
+	}
+
+	link := fields[0]
+	feed := user.DefaultFeed
+	if len(fields) > 1 {
+		feed = fields[1]
+	}
+	if feed != "" && feed != core.MainFeed {
+		if _, err := t.feedRepository.FindFeed(context2.Background(), user.Username, feed); err != nil {
+			if err == youpod.ErrFeedNotFound {
+				t.Send(chatID, "No such feed")
+				return
+			}
+			log.WithError(err).WithField("user", user.Username).Error("failed to find feed")
+			t.SendInternalError(chatID)
+			return
+		}
+	}
+
+	go func() {
+		sub, err := t.subscriptionService.Subscribe(user, chatID, feed, link)
+		if err != nil {
+			if err == youpod.ErrNotPlaylist {
+				t.Send(chatID, "Only YouTube channels, playlists and podcast feeds can be subscribed to")
+				return
+			}
+			log.WithError(err).WithField("user", user.Username).Error("failed to subscribe")
+			t.Send(chatID, "Failed to subscribe. Please try again later")
+			return
+		}
+		t.Send(chatID, fmt.Sprintf("Subscribed to \"%s\". New episodes will be added to your feed automatically", sub.Title))
+	}()
+}
+
+//unsubscribe accepts either subscription ID or its position in /subscriptions list
+func (t *Telegram) unsubscribe(user core.User, chatID int64, arg string) {
+	if arg == "" {
+		t.Send(chatID, "Usage: /unsubscribe <number from /subscriptions>")
+		return
+	}
+
+	ID := arg
+	if n, err := strconv.Atoi(arg); err == nil {
+		ss, err := t.subscriptionService.Subscriptions(user)
+		if err != nil {
+			log.WithError(err).WithField("user", user.Username).Error("failed to get subscriptions")
+			t.SendInternalError(chatID)
+			return
+		}
+		if n < 1 || n > len(ss) {
+			t.Send(chatID, "No such subscription")
+			return
+		}
+		ID = ss[n-1].ID
+	}
+
+	if err := t.subscriptionService.Unsubscribe(user, ID); err != nil {
+		if err == youpod.ErrSubscriptionNotFound {
+			t.Send(chatID, "No such subscription")
+			return
+		}
+		log.WithError(err).WithField("user", user.Username).Error("failed to unsubscribe")
+		t.SendInternalError(chatID)
+		return
+	}
+
+	t.Send(chatID, "Unsubscribed")
+}
+
+func (t *Telegram) subscriptions(user core.User, chatID int64) {
+	ss, err := t.subscriptionService.Subscriptions(user)
+	if err != nil {
+		log.WithError(err).WithField("user", user.Username).Error("failed to get subscriptions")
+		t.SendInternalError(chatID)
+		return
+	}
+
+	if len(ss) == 0 {
+		t.Send(chatID, "You have no subscriptions. Use /subscribe <channel, playlist or podcast feed link> to add one")
+		return
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("Your subscriptions:\n")
+	for i, sub := range ss {
+		buf.WriteString(fmt.Sprintf("%d. %s - %s\n", i+1, sub.Title, sub.Link))
+	}
+	buf.WriteString("Use /unsubscribe <number> to remove one")
+
+	t.Send(chatID, buf.String())
+}
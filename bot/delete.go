@@ -0,0 +1,127 @@
+package bot
+
+import (
+	context2 "context"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/htim/youpod/core"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	deleteCallbackPrefix = "delete:"
+	recentEpisodesCount  = 10
+)
+
+//deleteMenu sends inline keyboard with recent episodes, pressing a button deletes the episode
+func (t *Telegram) deleteMenu(user core.User, chatID int64) {
+	files, _, err := t.userFiles(user)
+	if err != nil {
+		log.WithError(err).WithField("user", user.Username).Error("failed to get feeds")
+		t.SendInternalError(chatID)
+		return
+	}
+
+	if len(files) == 0 {
+		t.Send(chatID, "Your feeds are empty")
+		return
+	}
+
+	rows := make([][]tgbotapi.InlineKeyboardButton, 0, recentEpisodesCount)
+
+	for i := len(files) - 1; i >= 0 && len(rows) < recentEpisodesCount; i-- {
+		fileID := files[i]
+
+		name := fileID
+		if m, err := t.mediaService.GetFileMetadata(context2.Background(), user, fileID); err == nil {
+			name = m.Name
+		}
+
+		btn := tgbotapi.NewInlineKeyboardButtonData(name, deleteCallbackPrefix+fileID)
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(btn))
+	}
+
+	msg := tgbotapi.NewMessage(chatID, "Choose an episode to delete")
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+
+	if _, err := t.api.Send(msg); err != nil {
+		log.WithError(err).Error("failed to send delete menu")
+	}
+}
+
+func (t *Telegram) deleteEpisode(user core.User, q *tgbotapi.CallbackQuery, fileID string) {
+	name := fileID
+	if m, err := t.mediaService.GetFileMetadata(context2.Background(), user, fileID); err == nil {
+		name = m.Name
+	}
+
+	if err := t.deleteFile(user, fileID); err != nil {
+		log.WithError(err).WithField("user", user.Username).Error("failed to delete file")
+		t.answerCallback(q, "Failed to delete the episode. Please try again later")
+		return
+	}
+
+	t.answerCallback(q, "Deleted")
+
+	if q.Message != nil {
+		edit := tgbotapi.NewEditMessageText(q.Message.Chat.ID, q.Message.MessageID, fmt.Sprintf("\"%s\" is deleted", name))
+		if _, err := t.api.Send(edit); err != nil {
+			log.WithError(err).Error("failed to edit delete menu")
+		}
+	}
+}
+
+//deleteFile removes file from user feeds first, so it disappears from the feeds even if store cleanup fails
+func (t *Telegram) deleteFile(user core.User, fileID string) error {
+	_, ff, err := t.userFiles(user)
+	if err != nil {
+		return errors.Wrap(err, "cannot get feeds")
+	}
+
+	owned := false
+	for _, id := range user.Files {
+		if id == fileID {
+			owned = true
+			if err := t.userService.RemoveFileFromUser(context2.Background(), user, fileID); err != nil {
+				return errors.Wrap(err, "cannot remove file from user")
+			}
+			break
+		}
+	}
+
+	for _, f := range ff {
+		if f.HasFile(fileID) {
+			owned = true
+			if err := t.feedRepository.RemoveFileFromFeed(context2.Background(), f, fileID); err != nil {
+				return errors.Wrapf(err, "cannot remove file from feed '%s'", f.Name)
+			}
+		}
+	}
+
+	if !owned {
+		return errors.Errorf("file '%s' does not belong to user '%s'", fileID, user.Username)
+	}
+
+	if err := t.mediaService.DeleteFile(context2.Background(), user, fileID); err != nil {
+		return errors.Wrap(err, "cannot delete file")
+	}
+
+	return nil
+}
+
+//userFiles returns files of the main feed followed by files of named feeds
+func (t *Telegram) userFiles(user core.User) ([]string, []core.Feed, error) {
+	ff, err := t.feedRepository.FindFeedsByUser(context2.Background(), user.Username)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	files := make([]string, 0, len(user.Files))
+	files = append(files, user.Files...)
+	for _, f := range ff {
+		files = append(files, f.Files...)
+	}
+
+	return files, ff, nil
+}
@@ -0,0 +1,269 @@
+package bot
+
+import (
+	"bytes"
+	context2 "context"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/htim/youpod"
+	"github.com/htim/youpod/core"
+	"github.com/rs/xid"
+	log "github.com/sirupsen/logrus"
+	"strings"
+	"time"
+)
+
+const (
+	feedCallbackPrefix = "feed:"
+)
+
+//pendingItem is either a link or an uploaded file
+type pendingItem struct {
+	owner  int64 //telegram ID of the user who sent the item, only the owner chooses its feed
+	chatID int64
+	link   string
+	upload *core.Upload
+}
+
+//askFeed sends inline keyboard with user feeds, the item is enqueued after user chooses one
+func (t *Telegram) askFeed(p pendingItem, ff []core.Feed) {
+	key := xid.New().String()
+	t.pending.Add(key, p)
+
+	rows := make([][]tgbotapi.InlineKeyboardButton, 0, len(ff)+1)
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("Main feed", feedCallbackPrefix+key+":"+core.MainFeed),
+	))
+	for _, f := range ff {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(feedTitle(f), feedCallbackPrefix+key+":"+f.Name),
+		))
+	}
+
+	msg := tgbotapi.NewMessage(p.chatID, "Which feed should it go to? Use /defaultfeed to stop asking")
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+
+	if _, err := t.api.Send(msg); err != nil {
+		log.WithError(err).Error("failed to send feed choice")
+	}
+}
+
+func (t *Telegram) chooseFeed(user core.User, q *tgbotapi.CallbackQuery, data string) {
+	parts := strings.SplitN(data, ":", 2)
+	if len(parts) != 2 {
+		t.answerCallback(q, "")
+		return
+	}
+
+	v, ok := t.pending.Get(parts[0])
+	if !ok {
+		t.answerCallback(q, "The link has expired, please send it again")
+		return
+	}
+	p := v.(pendingItem)
+	if p.owner != int64(q.From.ID) {
+		log.WithField("user", user.Username).Warn("feed choice of another user is rejected")
+		t.answerCallback(q, "")
+		return
+	}
+	t.pending.Remove(parts[0])
+
+	feed := parts[1]
+	if feed != core.MainFeed {
+		if _, err := t.feedRepository.FindFeed(context2.Background(), user.Username, feed); err != nil {
+			log.WithError(err).WithField("user", user.Username).Error("failed to find feed")
+			t.answerCallback(q, "No such feed")
+			return
+		}
+	}
+
+	t.answerCallback(q, "")
+
+	if q.Message != nil {
+		edit := tgbotapi.NewEditMessageText(q.Message.Chat.ID, q.Message.MessageID, fmt.Sprintf("Adding to %s feed", feed))
+		if _, err := t.api.Send(edit); err != nil {
+			log.WithError(err).Error("failed to edit feed choice")
+		}
+	}
+
+	t.enqueuePending(user, feed, p)
+}
+
+func (t *Telegram) feedUrl(user core.User, feed string) string {
+	return t.rssService.FeedUrl(user, core.Feed{Name: feed})
+}
+
+//newFeed handles /newfeed <name> [title]
+func (t *Telegram) newFeed(user core.User, chatID int64, args string) {
+	fields := strings.Fields(args)
+	if len(fields) == 0 {
+		t.Send(chatID, "Usage: /newfeed <name> [title]. Name may contain lowercase letters, digits, '-' and '_'")
+		return
+	}
+
+	name := fields[0]
+	if !core.ValidFeedName(name) {
+		t.Send(chatID, "Invalid feed name. Name may contain up to 20 lowercase letters, digits, '-' and '_'")
+		return
+	}
+
+	title := strings.TrimSpace(strings.TrimPrefix(args, name))
+	if title == "" {
+		title = name
+	}
+
+	if _, err := t.feedRepository.FindFeed(context2.Background(), user.Username, name); err != youpod.ErrFeedNotFound {
+		if err != nil {
+			log.WithError(err).WithField("user", user.Username).Error("failed to find feed")
+			t.SendInternalError(chatID)
+			return
+		}
+		t.Send(chatID, "Feed with this name already exists")
+		return
+	}
+
+	f := core.Feed{
+		ID:        xid.New().String(),
+		Username:  user.Username,
+		Name:      name,
+		Title:     title,
+		Files:     make([]string, 0),
+		CreatedAt: time.Now(),
+	}
+
+	if err := t.feedRepository.SaveFeed(context2.Background(), f); err != nil {
+		log.WithError(err).WithField("user", user.Username).Error("failed to save feed")
+		t.SendInternalError(chatID)
+		return
+	}
+
+	t.Send(chatID, fmt.Sprintf("Feed \"%s\" is created: %s", title, t.rssService.FeedUrl(user, f)))
+}
+
+func (t *Telegram) feeds(user core.User, chatID int64) {
+	ff, err := t.feedRepository.FindFeedsByUser(context2.Background(), user.Username)
+	if err != nil {
+		log.WithError(err).WithField("user", user.Username).Error("failed to get feeds")
+		t.SendInternalError(chatID)
+		return
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("Your feeds:\n")
+	buf.WriteString(fmt.Sprintf("%s - %s\n", core.MainFeed, t.rssService.UserFeedUrl(user)))
+	for _, f := range ff {
+		buf.WriteString(fmt.Sprintf("%s (%s) - %s\n", f.Name, f.Title, t.rssService.FeedUrl(user, f)))
+	}
+	if user.DefaultFeed != "" {
+		buf.WriteString(fmt.Sprintf("New links go to %s feed", user.DefaultFeed))
+	}
+
+	t.Send(chatID, buf.String())
+}
+
+//editFeed handles /editfeed <name> <title|description|artwork> <value>
+func (t *Telegram) editFeed(user core.User, chatID int64, args string) {
+	fields := strings.SplitN(args, " ", 3)
+	if len(fields) < 3 {
+		t.Send(chatID, "Usage: /editfeed <name> <title|description|artwork> <value>")
+		return
+	}
+
+	f, err := t.feedRepository.FindFeed(context2.Background(), user.Username, fields[0])
+	if err != nil {
+		if err == youpod.ErrFeedNotFound {
+			t.Send(chatID, "No such feed")
+			return
+		}
+		log.WithError(err).WithField("user", user.Username).Error("failed to find feed")
+		t.SendInternalError(chatID)
+		return
+	}
+
+	value := strings.TrimSpace(fields[2])
+
+	switch fields[1] {
+	case "title":
+		f.Title = value
+	case "description":
+		f.Description = value
+	case "artwork":
+		if !strings.HasPrefix(value, "http://") && !strings.HasPrefix(value, "https://") {
+			t.Send(chatID, "Artwork must be an image url")
+			return
+		}
+		f.Artwork = value
+	default:
+		t.Send(chatID, "Only title, description and artwork can be changed")
+		return
+	}
+
+	if err := t.feedRepository.SaveFeed(context2.Background(), f); err != nil {
+		log.WithError(err).WithField("user", user.Username).Error("failed to save feed")
+		t.SendInternalError(chatID)
+		return
+	}
+
+	t.Send(chatID, "Feed is updated")
+}
+
+//feed handles /feed [name]
+func (t *Telegram) feed(user core.User, chatID int64, name string) {
+	if name == "" || name == core.MainFeed {
+		t.Send(chatID, fmt.Sprintf("Your feed url: %s", t.rssService.UserFeedUrl(user)))
+		return
+	}
+
+	f, err := t.feedRepository.FindFeed(context2.Background(), user.Username, name)
+	if err != nil {
+		if err == youpod.ErrFeedNotFound {
+			t.Send(chatID, "No such feed")
+			return
+		}
+		log.WithError(err).WithField("user", user.Username).Error("failed to find feed")
+		t.SendInternalError(chatID)
+		return
+	}
+
+	t.Send(chatID, fmt.Sprintf("Feed \"%s\" url: %s", feedTitle(f), t.rssService.FeedUrl(user, f)))
+}
+
+//defaultFeed handles /defaultfeed [name], without name user is asked to choose a feed for every link
+func (t *Telegram) defaultFeed(user core.User, chatID int64, name string) {
+	if err := t.setDefaultFeed(user, name); err != nil {
+		if err == youpod.ErrFeedNotFound {
+			t.Send(chatID, "No such feed")
+			return
+		}
+		log.WithError(err).WithField("user", user.Username).Error("failed to set default feed")
+		t.SendInternalError(chatID)
+		return
+	}
+
+	t.Send(chatID, defaultFeedMessage(name))
+}
+
+func (t *Telegram) setDefaultFeed(user core.User, name string) error {
+	if name != "" && name != core.MainFeed {
+		if _, err := t.feedRepository.FindFeed(context2.Background(), user.Username, name); err != nil {
+			return err
+		}
+	}
+
+	user.DefaultFeed = name
+	return t.userService.UpdateSettings(context2.Background(), user)
+}
+
+func defaultFeedMessage(name string) string {
+	if name == "" {
+		return "I will ask which feed to use for every new link"
+	}
+	return fmt.Sprintf("New links will go to %s feed", name)
+}
+
+func feedTitle(f core.Feed) string {
+	if f.Title != "" {
+		return f.Title
+	}
+	return f.Name
+}
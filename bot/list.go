@@ -0,0 +1,137 @@
+package bot
+
+import (
+	"bytes"
+	context2 "context"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/htim/youpod/core"
+	log "github.com/sirupsen/logrus"
+	"strconv"
+)
+
+const (
+	listCallbackPrefix = "list:"
+	episodesPerPage    = 10
+)
+
+type episode struct {
+	fileID string
+	feed   string
+}
+
+func (t *Telegram) listEpisodes(user core.User, chatID int64) {
+	text, markup, err := t.episodesPage(user, 0)
+	if err != nil {
+		log.WithError(err).WithField("user", user.Username).Error("failed to list episodes")
+		t.SendInternalError(chatID)
+		return
+	}
+
+	msg := tgbotapi.NewMessage(chatID, text)
+	if markup != nil {
+		msg.ReplyMarkup = *markup
+	}
+
+	if _, err := t.api.Send(msg); err != nil {
+		log.WithError(err).Error("failed to send episodes list")
+	}
+}
+
+//turnEpisodesPage handles navigation buttons of /list by editing the original message
+func (t *Telegram) turnEpisodesPage(user core.User, q *tgbotapi.CallbackQuery, data string) {
+	page, err := strconv.Atoi(data)
+	if err != nil || q.Message == nil {
+		t.answerCallback(q, "")
+		return
+	}
+
+	text, markup, err := t.episodesPage(user, page)
+	if err != nil {
+		log.WithError(err).WithField("user", user.Username).Error("failed to list episodes")
+		t.answerCallback(q, "Internal error. Please try again later")
+		return
+	}
+
+	t.answerCallback(q, "")
+
+	edit := tgbotapi.NewEditMessageText(q.Message.Chat.ID, q.Message.MessageID, text)
+	edit.ReplyMarkup = markup
+	if _, err := t.api.Send(edit); err != nil {
+		log.WithError(err).Error("failed to edit episodes list")
+	}
+}
+
+//episodesPage renders page of episodes, navigation markup is nil when everything fits into one page
+func (t *Telegram) episodesPage(user core.User, page int) (string, *tgbotapi.InlineKeyboardMarkup, error) {
+	ee, err := t.episodes(user)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if len(ee) == 0 {
+		return "You have no episodes yet. Send me a link to a YouTube video", nil, nil
+	}
+
+	pages := (len(ee) + episodesPerPage - 1) / episodesPerPage
+	if page < 0 {
+		page = 0
+	}
+	if page >= pages {
+		page = pages - 1
+	}
+
+	from := page * episodesPerPage
+	to := from + episodesPerPage
+	if to > len(ee) {
+		to = len(ee)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(fmt.Sprintf("Episodes %d-%d of %d:\n", from+1, to, len(ee)))
+	for i, e := range ee[from:to] {
+		name := e.fileID
+		if m, err := t.mediaService.GetFileMetadata(context2.Background(), user, e.fileID); err == nil {
+			name = m.Name
+		}
+		if e.feed != core.MainFeed {
+			name = fmt.Sprintf("[%s] %s", e.feed, name)
+		}
+		buf.WriteString(fmt.Sprintf("%d. %s\n", from+i+1, name))
+	}
+
+	if pages == 1 {
+		return buf.String(), nil, nil
+	}
+
+	buttons := make([]tgbotapi.InlineKeyboardButton, 0, 2)
+	if page > 0 {
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardButtonData("« Newer", listCallbackPrefix+strconv.Itoa(page-1)))
+	}
+	if page < pages-1 {
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardButtonData("Older »", listCallbackPrefix+strconv.Itoa(page+1)))
+	}
+	markup := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(buttons...))
+
+	return buf.String(), &markup, nil
+}
+
+//episodes returns episodes of the main feed followed by episodes of named feeds, each feed newest first
+func (t *Telegram) episodes(user core.User) ([]episode, error) {
+	ff, err := t.feedRepository.FindFeedsByUser(context2.Background(), user.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	ee := make([]episode, 0, len(user.Files))
+	for i := len(user.Files) - 1; i >= 0; i-- {
+		ee = append(ee, episode{fileID: user.Files[i], feed: core.MainFeed})
+	}
+	for _, f := range ff {
+		for i := len(f.Files) - 1; i >= 0; i-- {
+			ee = append(ee, episode{fileID: f.Files[i], feed: f.Name})
+		}
+	}
+
+	return ee, nil
+}
@@ -0,0 +1,95 @@
+package bot
+
+import (
+	"bytes"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/htim/youpod/core"
+	"strings"
+)
+
+type command struct {
+	name        string
+	usage       string //arguments shown in /help
+	description string
+	handle      func(user core.User, chatID int64, args string)
+}
+
+//newCommands lists bot commands in the order they are shown in /help
+func (t *Telegram) newCommands() []command {
+	return []command{
+		{name: "start", description: "show welcome message", handle: func(user core.User, chatID int64, args string) {
+			t.start(user, chatID)
+		}},
+		{name: "help", description: "show this help", handle: func(user core.User, chatID int64, args string) {
+			t.help(chatID)
+		}},
+		{name: "feed", usage: "[name]", description: "send feed url again", handle: t.feed},
+		{name: "list", description: "list your episodes", handle: func(user core.User, chatID int64, args string) {
+			t.listEpisodes(user, chatID)
+		}},
+		{name: "cancel", description: "abort your jobs in progress", handle: func(user core.User, chatID int64, args string) {
+			t.cancel(user, chatID)
+		}},
+		{name: "settings", description: "show and change your settings", handle: func(user core.User, chatID int64, args string) {
+			t.settings(user, chatID)
+		}},
+		{name: "transcripts", usage: "[language code|off]", description: "publish subtitles of new videos as transcripts", handle: t.transcripts},
+		{name: "delete", description: "delete one of recent episodes", handle: func(user core.User, chatID int64, args string) {
+			t.deleteMenu(user, chatID)
+		}},
+		{name: "feeds", description: "list your feeds", handle: func(user core.User, chatID int64, args string) {
+			t.feeds(user, chatID)
+		}},
+		{name: "newfeed", usage: "<name> [title]", description: "create a new feed", handle: t.newFeed},
+		{name: "editfeed", usage: "<name> <title|description|artwork> <value>", description: "change feed details", handle: t.editFeed},
+		{name: "defaultfeed", usage: "[name]", description: "set feed new links go to, without name you are asked every time", handle: t.defaultFeed},
+		{name: "subscribe", usage: "<channel, playlist or podcast feed link> [feed]", description: "add new episodes of a channel or podcast automatically", handle: t.subscribe},
+		{name: "unsubscribe", usage: "<number>", description: "remove a subscription", handle: t.unsubscribe},
+		{name: "subscriptions", description: "list your subscriptions", handle: func(user core.User, chatID int64, args string) {
+			t.subscriptions(user, chatID)
+		}},
+		{name: "rotate", description: "invalidate your feed urls and get new ones", handle: func(user core.User, chatID int64, args string) {
+			t.rotate(user, chatID)
+		}},
+	}
+}
+
+//handleCommand dispatches '/command args' messages, unknown commands are answered with help
+func (t *Telegram) handleCommand(user core.User, m *tgbotapi.Message) {
+	chatID := m.Chat.ID
+	args := strings.TrimSpace(m.CommandArguments())
+	name := strings.ToLower(m.Command())
+
+	for _, c := range t.commands {
+		if c.name == name {
+			c.handle(user, chatID, args)
+			return
+		}
+	}
+
+	t.Send(chatID, "Unknown command.\n\n"+t.helpText())
+}
+
+func (t *Telegram) helpText() string {
+	var buf bytes.Buffer
+	buf.WriteString("Send me a link to a YouTube video, playlist or channel, an audio file, a web page with audio or a podcast feed and it will appear in your podcast feed.\n\n")
+	buf.WriteString("Commands:\n")
+	for _, c := range t.commands {
+		buf.WriteString("/" + c.name)
+		if c.usage != "" {
+			buf.WriteString(" " + c.usage)
+		}
+		buf.WriteString(fmt.Sprintf(" - %s\n", c.description))
+	}
+	return buf.String()
+}
+
+func (t *Telegram) start(user core.User, chatID int64) {
+	t.Send(chatID, fmt.Sprintf("Hi! I turn YouTube videos into podcast episodes.\n\nYour feed url: %s. Add it to your favourite podcast app\n\n%s",
+		t.rssService.UserFeedUrl(user), t.helpText()))
+}
+
+func (t *Telegram) help(chatID int64) {
+	t.Send(chatID, t.helpText())
+}
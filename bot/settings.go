@@ -0,0 +1,259 @@
+package bot
+
+import (
+	"bytes"
+	context2 "context"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/htim/youpod/core"
+	log "github.com/sirupsen/logrus"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	settingsCallbackPrefix = "settings:"
+
+	defaultFeedSetting = "defaultfeed"
+	codecSetting       = "codec"
+	bitrateSetting     = "bitrate"
+	normalizeSetting   = "normalize"
+	trimSetting        = "trimsilence"
+	speedSetting       = "speed"
+)
+
+//languageCode is a BCP 47 like language tag as YouTube uses them
+var languageCode = regexp.MustCompile(`^[a-z]{2,3}(-[a-z0-9]{2,8})?$`)
+
+//settings sends current settings with inline keyboard to change them
+func (t *Telegram) settings(user core.User, chatID int64) {
+	text, markup, err := t.settingsMenu(user)
+	if err != nil {
+		log.WithError(err).WithField("user", user.Username).Error("failed to render settings")
+		t.SendInternalError(chatID)
+		return
+	}
+
+	msg := tgbotapi.NewMessage(chatID, text)
+	if len(markup.InlineKeyboard) > 0 {
+		msg.ReplyMarkup = markup
+	}
+
+	if _, err := t.api.Send(msg); err != nil {
+		log.WithError(err).Error("failed to send settings")
+	}
+}
+
+//changeSetting handles 'settings:<setting>:<value>' buttons
+func (t *Telegram) changeSetting(user core.User, q *tgbotapi.CallbackQuery, data string) {
+	parts := strings.SplitN(data, ":", 2)
+	if len(parts) != 2 {
+		t.answerCallback(q, "")
+		return
+	}
+
+	var err error
+	switch parts[0] {
+	case defaultFeedSetting:
+		value := parts[1]
+		if err = t.setDefaultFeed(user, value); err == nil {
+			user.DefaultFeed = value
+		}
+	case codecSetting:
+		if !core.ValidCodec(parts[1]) {
+			t.answerCallback(q, "")
+			return
+		}
+		user.Codec = parts[1]
+		err = t.userService.UpdateSettings(context2.Background(), user)
+	case bitrateSetting:
+		bitrate, convErr := strconv.Atoi(parts[1])
+		if convErr != nil || !core.ValidBitrate(bitrate) {
+			t.answerCallback(q, "")
+			return
+		}
+		user.Bitrate = bitrate
+		err = t.userService.UpdateSettings(context2.Background(), user)
+	case normalizeSetting:
+		user.Normalize = parts[1] == "on"
+		err = t.userService.UpdateSettings(context2.Background(), user)
+	case trimSetting:
+		user.TrimSilence = parts[1] == "on"
+		err = t.userService.UpdateSettings(context2.Background(), user)
+	case speedSetting:
+		speed, convErr := strconv.ParseFloat(parts[1], 64)
+		if convErr != nil || !core.ValidSpeed(speed) {
+			t.answerCallback(q, "")
+			return
+		}
+		user.Speed = speed
+		err = t.userService.UpdateSettings(context2.Background(), user)
+	default:
+		t.answerCallback(q, "")
+		return
+	}
+
+	if err != nil {
+		log.WithError(err).WithField("user", user.Username).Error("failed to change setting")
+		t.answerCallback(q, "Failed to change the setting. Please try again later")
+		return
+	}
+
+	t.answerCallback(q, "Saved")
+
+	if q.Message == nil {
+		return
+	}
+
+	text, markup, err := t.settingsMenu(user)
+	if err != nil {
+		log.WithError(err).WithField("user", user.Username).Error("failed to render settings")
+		return
+	}
+
+	edit := tgbotapi.NewEditMessageText(q.Message.Chat.ID, q.Message.MessageID, text)
+	edit.ReplyMarkup = &markup
+	if _, err := t.api.Send(edit); err != nil {
+		log.WithError(err).Error("failed to edit settings")
+	}
+}
+
+func (t *Telegram) settingsMenu(user core.User) (string, tgbotapi.InlineKeyboardMarkup, error) {
+	ff, err := t.feedRepository.FindFeedsByUser(context2.Background(), user.Username)
+	if err != nil {
+		return "", tgbotapi.InlineKeyboardMarkup{}, err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("Your settings:\n")
+
+	defaultFeed := "ask every time"
+	if user.DefaultFeed != "" {
+		defaultFeed = user.DefaultFeed
+	}
+	buf.WriteString(fmt.Sprintf("New links go to: %s\n", defaultFeed))
+
+	format := user.AudioFormat()
+	buf.WriteString(fmt.Sprintf("Audio format: %s, %s\n", format.Codec, bitrateTitle(format.Bitrate)))
+
+	processing := user.Processing()
+	buf.WriteString(fmt.Sprintf("Loudness normalization: %s\n", onOff(processing.Normalize)))
+	buf.WriteString(fmt.Sprintf("Silence trimming: %s\n", onOff(processing.TrimSilence)))
+	buf.WriteString(fmt.Sprintf("Speed: %sx\n", speedTitle(processing.Speed)))
+	buf.WriteString(fmt.Sprintf("Transcripts: %s\n", transcriptsTitle(user.TranscriptLanguage)))
+
+	rows := make([][]tgbotapi.InlineKeyboardButton, 0)
+
+	if len(ff) > 0 {
+		buf.WriteString("\nChoose where new links go:")
+
+		feedButtons := []tgbotapi.InlineKeyboardButton{
+			settingButton("Ask every time", defaultFeedSetting, "", user.DefaultFeed),
+			settingButton("Main feed", defaultFeedSetting, core.MainFeed, user.DefaultFeed),
+		}
+		for _, f := range ff {
+			feedButtons = append(feedButtons, settingButton(feedTitle(f), defaultFeedSetting, f.Name, user.DefaultFeed))
+		}
+		for _, b := range feedButtons {
+			rows = append(rows, tgbotapi.NewInlineKeyboardRow(b))
+		}
+	} else {
+		buf.WriteString("\nCreate more feeds with /newfeed to choose where new links go")
+	}
+
+	buf.WriteString("\nAudio format and processing apply to new episodes only")
+
+	codecs := make([]tgbotapi.InlineKeyboardButton, 0)
+	for _, c := range []string{core.Mp3, core.M4a, core.Opus} {
+		codecs = append(codecs, settingButton(c, codecSetting, c, format.Codec))
+	}
+	rows = append(rows, codecs)
+
+	bitrates := []tgbotapi.InlineKeyboardButton{
+		settingButton(bitrateTitle(0), bitrateSetting, "0", strconv.Itoa(format.Bitrate)),
+	}
+	for _, b := range core.Bitrates {
+		bitrates = append(bitrates, settingButton(strconv.Itoa(b), bitrateSetting, strconv.Itoa(b), strconv.Itoa(format.Bitrate)))
+	}
+	rows = append(rows, bitrates)
+
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		settingButton("Normalize loudness", normalizeSetting, "on", onOff(processing.Normalize)),
+		settingButton("Keep loudness", normalizeSetting, "off", onOff(processing.Normalize)),
+	))
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		settingButton("Trim silence", trimSetting, "on", onOff(processing.TrimSilence)),
+		settingButton("Keep silence", trimSetting, "off", onOff(processing.TrimSilence)),
+	))
+
+	speeds := make([]tgbotapi.InlineKeyboardButton, 0, len(core.Speeds))
+	for _, sp := range core.Speeds {
+		speeds = append(speeds, settingButton(speedTitle(sp)+"x", speedSetting, speedTitle(sp), speedTitle(processing.Speed)))
+	}
+	rows = append(rows, speeds)
+
+	return buf.String(), tgbotapi.NewInlineKeyboardMarkup(rows...), nil
+}
+
+//transcripts handles /transcripts [language code|off]
+func (t *Telegram) transcripts(user core.User, chatID int64, arg string) {
+	if arg == "" {
+		t.Send(chatID, fmt.Sprintf("Transcripts: %s. Usage: /transcripts <language code, e.g. en>, /transcripts off to disable", transcriptsTitle(user.TranscriptLanguage)))
+		return
+	}
+
+	lang := strings.ToLower(arg)
+	if lang == "off" {
+		lang = ""
+	} else if !languageCode.MatchString(lang) {
+		t.Send(chatID, "Language code must look like 'en' or 'pt-br'")
+		return
+	}
+
+	user.TranscriptLanguage = lang
+	if err := t.userService.UpdateSettings(context2.Background(), user); err != nil {
+		log.WithError(err).WithField("user", user.Username).Error("failed to save transcript language")
+		t.SendInternalError(chatID)
+		return
+	}
+
+	if lang == "" {
+		t.Send(chatID, "Transcripts are disabled")
+		return
+	}
+	t.Send(chatID, fmt.Sprintf("Subtitles in '%s' will be published as transcripts of new episodes when videos have them", lang))
+}
+
+func transcriptsTitle(lang string) string {
+	if lang == "" {
+		return "off"
+	}
+	return lang
+}
+
+func bitrateTitle(bitrate int) string {
+	if bitrate == 0 {
+		return "auto"
+	}
+	return fmt.Sprintf("%d kbit/s", bitrate)
+}
+
+func speedTitle(speed float64) string {
+	return strconv.FormatFloat(speed, 'f', -1, 64)
+}
+
+func onOff(on bool) string {
+	if on {
+		return "on"
+	}
+	return "off"
+}
+
+//settingButton marks button of the current value
+func settingButton(text, setting, value, current string) tgbotapi.InlineKeyboardButton {
+	if value == current {
+		text = "✓ " + text
+	}
+	return tgbotapi.NewInlineKeyboardButtonData(text, settingsCallbackPrefix+setting+":"+value)
+}
@@ -0,0 +1,115 @@
+package bot
+
+import (
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/htim/youpod/core"
+	log "github.com/sirupsen/logrus"
+	"time"
+)
+
+//statusInterval limits edits of a status message by progress, Telegram rejects bots editing messages too often
+const statusInterval = 3 * time.Second
+
+//status is a message edited as the job goes through its states
+type status struct {
+	chatID    int64
+	messageID int
+	state     core.JobState
+	text      string
+	editedAt  time.Time
+}
+
+//sendStatus sends a message which is edited later to show job progress. The job may have been updated
+//while the message was being sent, the latest of such updates is shown at once
+func (t *Telegram) sendStatus(j core.Job, text string) {
+	m, err := t.api.Send(tgbotapi.NewMessage(j.ChatID, text))
+	if err != nil {
+		log.WithError(err).Error("failed to send message to telegram")
+		return
+	}
+
+	t.statusMu.Lock()
+	defer t.statusMu.Unlock()
+
+	s := &status{
+		chatID:    j.ChatID,
+		messageID: m.MessageID,
+		state:     j.State,
+		text:      text,
+		editedAt:  time.Now(),
+	}
+	t.statuses.Add(j.ID, s)
+
+	if v, ok := t.earlyUpdates.Get(j.ID); ok {
+		t.earlyUpdates.Remove(j.ID)
+		t.editStatus(s, v.(core.Job))
+	}
+}
+
+//updateStatus edits status message of the job. State changes are shown at once, progress is throttled
+func (t *Telegram) updateStatus(j core.Job) {
+	t.statusMu.Lock()
+	defer t.statusMu.Unlock()
+
+	v, ok := t.statuses.Get(j.ID)
+	if !ok {
+		t.earlyUpdates.Add(j.ID, j)
+		return
+	}
+	t.editStatus(v.(*status), j)
+}
+
+func (t *Telegram) editStatus(s *status, j core.Job) {
+	if j.IsFinished() {
+		t.statuses.Remove(j.ID)
+	} else if j.State == s.state && time.Since(s.editedAt) < statusInterval {
+		return
+	}
+
+	text := statusText(j)
+	if text == s.text {
+		return
+	}
+
+	s.state, s.text, s.editedAt = j.State, text, time.Now()
+
+	if _, err := t.api.Send(tgbotapi.NewEditMessageText(s.chatID, s.messageID, text)); err != nil {
+		log.WithError(err).WithField("job", j.ID).Warn("failed to edit status message")
+	}
+}
+
+func statusText(j core.Job) string {
+	var text string
+	switch j.State {
+	case core.JobDownloading:
+		text = "Downloading…"
+		if j.Total > 0 {
+			text = fmt.Sprintf("Downloading %d%%…", j.Done*100/j.Total)
+		} else if j.Done > 0 {
+			text = fmt.Sprintf("Downloading %s…", megabytes(j.Done))
+		}
+	case core.JobProcessing:
+		text = "Processing audio…"
+	case core.JobUploading:
+		text = "Uploading…"
+		if j.Total > 0 {
+			text = fmt.Sprintf("Uploading %s/%s…", megabytes(j.Done), megabytes(j.Total))
+		}
+	case core.JobDone:
+		text = "Done"
+	case core.JobDuplicate:
+		text = "Already in your feed"
+	case core.JobFailed:
+		text = "Failed"
+	case core.JobCancelled:
+		text = "Cancelled"
+	default:
+		text = "Queued"
+	}
+	return fmt.Sprintf("%s\nJob ID: %s", text, j.ID)
+}
+
+func megabytes(b int64) string {
+	return fmt.Sprintf("%d MB", b>>20)
+}
@@ -4,6 +4,7 @@ import (
 	context2 "context"
 	"fmt"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	lru "github.com/hashicorp/golang-lru"
 	"github.com/htim/youpod"
 	"github.com/htim/youpod/auth"
 	"github.com/htim/youpod/core"
@@ -11,21 +12,44 @@ import (
 	"github.com/rs/xid"
 	log "github.com/sirupsen/logrus"
 	"golang.org/x/net/context"
-	"strconv"
+	"strings"
+	"sync"
 )
 
 type Telegram struct {
 	api *tgbotapi.BotAPI
 
 	userService    core.UserRepository
-	youtubeService core.YoutubeService
+	sourceService  core.SourceService
+	jobQueue       core.JobQueue
 	mediaService   core.MediaService
 	rssService     core.RssService
+	feedRepository core.FeedRepository
 
-	googleDriveAuth auth.OAuth2
+	subscriptionService core.SubscriptionService
+
+	googleDriveAuth      auth.OAuth2
+	oauthStateRepository core.OAuthStateRepository
 
 	updates tgbotapi.UpdatesChannel
 
+	//webhook mode is enabled when secret is set, updates are pushed to webhookUpdates by ServeWebhook
+	webhookSecret  string
+	webhookUpdates chan tgbotapi.Update
+
+	//links and uploads waiting for user to choose a feed
+	pending *lru.Cache
+
+	//status messages of jobs in progress by job ID and latest updates of jobs whose status message is not sent yet,
+	//both are guarded by statusMu
+	statusMu     sync.Mutex
+	statuses     *lru.Cache
+	earlyUpdates *lru.Cache
+
+	rotateListeners []func(user core.User)
+
+	commands []command
+
 	rootUrl string
 }
 
@@ -33,12 +57,17 @@ func NewTelegram(
 	telegramToken string,
 
 	userService core.UserRepository,
-	youtubeService core.YoutubeService,
+	sourceService core.SourceService,
+	jobQueue core.JobQueue,
 	mediaService core.MediaService,
 	rssService core.RssService,
+	feedRepository core.FeedRepository,
+	subscriptionService core.SubscriptionService,
 
 	googleDriveAuth auth.OAuth2,
+	oauthStateRepository core.OAuthStateRepository,
 	rootUrl string,
+	webhookSecret string,
 ) (*Telegram, error) {
 
 	api, err := tgbotapi.NewBotAPI(telegramToken)
@@ -46,35 +75,74 @@ func NewTelegram(
 		return nil, errors.Wrap(err, "failed to create Telegram API")
 	}
 
-	u := tgbotapi.NewUpdate(0)
-	u.Timeout = 60
+	pending, err := lru.New(1000)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to init pending links cache")
+	}
+
+	statuses, err := lru.New(1000)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to init status messages cache")
+	}
 
-	updates, err := api.GetUpdatesChan(u)
+	earlyUpdates, err := lru.New(100)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to get updates channel")
+		return nil, errors.Wrap(err, "failed to init early job updates cache")
 	}
 
-	return &Telegram{
+	t := &Telegram{
 		api: api,
 
 		userService:    userService,
-		youtubeService: youtubeService,
+		sourceService:  sourceService,
+		jobQueue:       jobQueue,
+		mediaService:   mediaService,
+		rssService:     rssService,
+		feedRepository: feedRepository,
+
+		subscriptionService: subscriptionService,
 
-		mediaService: mediaService,
-		rssService:   rssService,
+		webhookSecret: webhookSecret,
 
-		updates: updates,
+		pending:      pending,
+		statuses:     statuses,
+		earlyUpdates: earlyUpdates,
 
-		googleDriveAuth: googleDriveAuth,
+		googleDriveAuth:      googleDriveAuth,
+		oauthStateRepository: oauthStateRepository,
 
 		rootUrl: rootUrl,
-	}, nil
+	}
+
+	t.commands = t.newCommands()
+
+	if webhookSecret != "" {
+		err = t.useWebhook()
+	} else {
+		err = t.usePolling()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return t, nil
 }
 
 func (t *Telegram) Run() {
+	go t.listenJobs()
+
 	go func() {
 		for u := range t.updates {
 
+			if u.CallbackQuery != nil {
+				t.handleCallback(u.CallbackQuery)
+				continue
+			}
+
+			if u.Message == nil {
+				continue
+			}
+
 			telegramID := int64(u.Message.From.ID)
 			telegramUserName := u.Message.From.UserName
 			chatID := u.Message.Chat.ID
@@ -89,9 +157,17 @@ func (t *Telegram) Run() {
 						username = xid.New().String() + "_telegram"
 					}
 
+					token, err := newToken()
+					if err != nil {
+						log.WithError(err).Error("failed to generate token")
+						t.SendInternalError(chatID)
+						continue
+					}
+
 					user = core.User{
 						Username:   username,
 						TelegramID: telegramID,
+						Token:      token,
 					}
 
 					if err := t.userService.SaveUser(context.Background(), user); err != nil {
@@ -99,47 +175,183 @@ func (t *Telegram) Run() {
 						t.SendInternalError(chatID)
 						continue
 					}
+				} else {
+					log.WithError(err).Error("failed to get user")
+					t.SendInternalError(chatID)
 					continue
 				}
-
-				log.WithError(err).Error("failed to get user")
-				t.SendInternalError(chatID)
-				continue
 			}
 
-			if user.GDriveToken.AccessToken == "" {
-				t.RequestGDriveAuth(u.Message.Chat.ID, t.googleDriveAuth.URL(strconv.FormatInt(telegramID, 10)))
+			if user, err = t.ensureToken(user); err != nil {
+				log.WithError(err).WithField("user", user.Username).Error("failed to ensure token")
+				t.SendInternalError(chatID)
 				continue
 			}
 
-			if u.Message.Text != "" {
-				file, err := t.youtubeService.Download(user, u.Message.Text)
+			//google drive auth is not needed when files are kept in another store
+			if t.googleDriveAuth != nil && user.GDriveToken.AccessToken == "" {
+				url, err := t.gdriveAuthURL(telegramID)
 				if err != nil {
-					log.WithError(err).WithField("user", user.Username).Error("failed to download youtube video")
-					t.Send(chatID, "Failed to download video. Please try again later")
-					continue
-				}
-				id, err := t.mediaService.SaveFile(user, file)
-				if err != nil {
-					log.WithError(err).WithField("user", user.Username).Error("failed to save media")
-					t.Send(chatID, "Failed to save file in your Google Drive. Please try again later")
-					continue
-				}
-				if err = t.userService.AddFileToUser(context2.Background(), user, id); err != nil {
-					log.WithError(err).WithField("user", user.Username).Error("failed to update user file list")
+					log.WithError(err).WithField("user", user.Username).Error("failed to create auth url")
 					t.SendInternalError(chatID)
 					continue
 				}
+				t.RequestGDriveAuth(chatID, url)
+				continue
+			}
+
+			if u.Message.IsCommand() {
+				t.handleCommand(user, u.Message)
+				continue
+			}
 
-				t.Send(chatID, "Alright, the podcast based on this video will be available soon")
-				t.Send(chatID, t.rssService.UserFeedUrl(user))
+			if up, ok := messageUpload(u.Message); ok {
+				t.handleUpload(user, chatID, up)
+				continue
+			}
 
-				t.youtubeService.Cleanup(file)
+			if u.Message.Text != "" {
+				t.handleLink(user, chatID, u.Message.Text)
 			}
 		}
 	}()
 }
 
+func (t *Telegram) handleLink(user core.User, chatID int64, link string) {
+	link = strings.TrimSpace(link)
+	if strings.ContainsAny(link, " \n\t") {
+		t.Send(chatID, t.helpText())
+		return
+	}
+
+	t.dispatch(user, pendingItem{chatID: chatID, link: link})
+}
+
+//dispatch asks user to choose a feed when there are several of them and the default one is not set
+func (t *Telegram) dispatch(user core.User, p pendingItem) {
+	feed := user.DefaultFeed
+
+	if feed == "" {
+		ff, err := t.feedRepository.FindFeedsByUser(context2.Background(), user.Username)
+		if err != nil {
+			log.WithError(err).WithField("user", user.Username).Error("failed to get feeds")
+			t.SendInternalError(p.chatID)
+			return
+		}
+		if len(ff) > 0 {
+			p.owner = user.TelegramID
+			t.askFeed(p, ff)
+			return
+		}
+		feed = core.MainFeed
+	}
+
+	t.enqueuePending(user, feed, p)
+}
+
+func (t *Telegram) enqueuePending(user core.User, feed string, p pendingItem) {
+	if p.upload != nil {
+		t.enqueueUpload(user, p.chatID, feed, *p.upload)
+		return
+	}
+	t.enqueue(user, p.chatID, feed, p.link)
+}
+
+//enqueue inspects the link in background, as extractors may need to fetch it to tell whether they handle it
+func (t *Telegram) enqueue(user core.User, chatID int64, feed string, link string) {
+	go func() {
+		if !t.sourceService.Handles(context2.Background(), link) {
+			t.Send(chatID, "Sorry, I can't find any audio by this link")
+			return
+		}
+
+		if t.sourceService.IsPlaylist(context2.Background(), link) {
+			t.enqueuePlaylist(user, chatID, feed, link)
+			return
+		}
+
+		job, err := t.jobQueue.Enqueue(user, chatID, feed, link)
+		if err != nil {
+			log.WithError(err).WithField("user", user.Username).Error("failed to enqueue job")
+			t.SendInternalError(chatID)
+			return
+		}
+
+		t.sendStatus(job, fmt.Sprintf("Alright, the podcast based on this link will be available soon. Job ID: %s", job.ID))
+	}()
+}
+
+func (t *Telegram) enqueuePlaylist(user core.User, chatID int64, feed string, link string) {
+	t.Send(chatID, "Looking through the playlist...")
+
+	p, err := t.sourceService.Playlist(context2.Background(), link)
+	if err != nil {
+		log.WithError(err).WithField("user", user.Username).Error("failed to expand playlist")
+		t.Send(chatID, "Failed to read the playlist. Please try again later")
+		return
+	}
+
+	if len(p.Entries) == 0 {
+		t.Send(chatID, fmt.Sprintf("Playlist \"%s\" is empty", p.Title))
+		return
+	}
+
+	jobs, err := t.jobQueue.EnqueuePlaylist(user, chatID, feed, p)
+	if err != nil {
+		log.WithError(err).WithField("user", user.Username).Error("failed to enqueue playlist")
+		if len(jobs) == 0 {
+			t.SendInternalError(chatID)
+			return
+		}
+	}
+
+	t.Send(chatID, fmt.Sprintf("Found %d items in \"%s\", %d of them are queued. I will report on each of them", len(p.Entries), p.Title, len(jobs)))
+}
+
+//cancel aborts queued and running jobs of the user, status messages of the jobs show they are cancelled
+func (t *Telegram) cancel(user core.User, chatID int64) {
+	jobs := t.jobQueue.Cancel(user)
+	if len(jobs) == 0 {
+		t.Send(chatID, "You have no jobs in progress")
+		return
+	}
+	t.Send(chatID, fmt.Sprintf("Cancelled %d job(s)", len(jobs)))
+}
+
+//listenJobs shows progress of jobs and notifies users when their jobs are finished
+func (t *Telegram) listenJobs() {
+	for j := range t.jobQueue.Updates() {
+		t.updateStatus(j)
+
+		prefix := ""
+		if j.Playlist != "" {
+			prefix = fmt.Sprintf("[%s] ", j.Playlist)
+		}
+
+		switch j.State {
+		case core.JobDone, core.JobDuplicate:
+			user, err := t.userService.FindUserByUsername(context2.Background(), j.Username)
+			if err != nil {
+				log.WithError(err).WithField("job", j.ID).Error("failed to find job owner")
+				continue
+			}
+			format := "%s\"%s\" is now in your feed: %s"
+			if j.State == core.JobDuplicate {
+				format = "%s\"%s\" is already saved, it is in your feed: %s"
+			}
+			t.Send(j.ChatID, fmt.Sprintf(format, prefix, j.Title, t.feedUrl(user, j.Feed)))
+		case core.JobFailed:
+			name := j.Link
+			if j.Title != "" {
+				name = fmt.Sprintf("\"%s\"", j.Title)
+			} else if j.Upload != nil {
+				name = "the file"
+			}
+			t.Send(j.ChatID, fmt.Sprintf("%sFailed to process %s (job ID: %s). Please try again later", prefix, name, j.ID))
+		}
+	}
+}
+
 func (t *Telegram) SuccessfulAuth(telegramID int64, message string, onSend func()) error {
 	user, err := t.userService.FindUserByTelegramID(context2.Background(), telegramID)
 	if err != nil {
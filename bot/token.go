@@ -0,0 +1,101 @@
+package bot
+
+import (
+	context2 "context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"github.com/htim/youpod/core"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"time"
+)
+
+const (
+	tokenSize = 16
+
+	//oauthStateTTL limits how long google drive login link stays valid
+	oauthStateTTL = 15 * time.Minute
+)
+
+func newToken() (string, error) {
+	b := make([]byte, tokenSize)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Wrap(err, "cannot generate token")
+	}
+	return hex.EncodeToString(b), nil
+}
+
+//ensureToken generates token for users created before feed urls became private
+func (t *Telegram) ensureToken(user core.User) (core.User, error) {
+	if user.Token != "" {
+		return user, nil
+	}
+
+	token, err := newToken()
+	if err != nil {
+		return user, err
+	}
+
+	user.Token = token
+	if err := t.userService.UpdateToken(context2.Background(), user); err != nil {
+		return user, errors.Wrap(err, "cannot save user token")
+	}
+
+	return user, nil
+}
+
+//rotate invalidates all feed and file urls of user and sends the new ones
+func (t *Telegram) rotate(user core.User, chatID int64) {
+	token, err := newToken()
+	if err != nil {
+		log.WithError(err).WithField("user", user.Username).Error("failed to generate token")
+		t.SendInternalError(chatID)
+		return
+	}
+
+	user.Token = token
+	if err := t.userService.UpdateToken(context2.Background(), user); err != nil {
+		log.WithError(err).WithField("user", user.Username).Error("failed to save user")
+		t.SendInternalError(chatID)
+		return
+	}
+
+	for _, l := range t.rotateListeners {
+		l(user)
+	}
+
+	t.Send(chatID, fmt.Sprintf("Old links are not valid anymore. Your new feed url: %s", t.rssService.UserFeedUrl(user)))
+
+	ff, err := t.feedRepository.FindFeedsByUser(context2.Background(), user.Username)
+	if err != nil {
+		log.WithError(err).WithField("user", user.Username).Error("failed to get feeds")
+		return
+	}
+	if len(ff) > 0 {
+		t.feeds(user, chatID)
+	}
+}
+
+//OnRotate registers listener which is called after user token is changed, e.g. to evict cached responses
+func (t *Telegram) OnRotate(listener func(user core.User)) {
+	t.rotateListeners = append(t.rotateListeners, listener)
+}
+
+//gdriveAuthURL creates single use OAuth2 state bound to telegram user, so the callback cannot be forged
+func (t *Telegram) gdriveAuthURL(telegramID int64) (string, error) {
+	state, err := newToken()
+	if err != nil {
+		return "", err
+	}
+
+	if err := t.oauthStateRepository.SaveState(context2.Background(), core.OAuthState{
+		State:      state,
+		TelegramID: telegramID,
+		ExpiresAt:  time.Now().Add(oauthStateTTL),
+	}); err != nil {
+		return "", errors.Wrap(err, "cannot save oauth state")
+	}
+
+	return t.googleDriveAuth.URL(state), nil
+}
@@ -0,0 +1,82 @@
+package bot
+
+import (
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/htim/youpod/core"
+	log "github.com/sirupsen/logrus"
+	"strings"
+)
+
+//messageUpload extracts audio, voice note, video or document attached to the message
+func messageUpload(m *tgbotapi.Message) (core.Upload, bool) {
+	var u core.Upload
+
+	switch {
+	case m.Audio != nil:
+		u = core.Upload{
+			TelegramFileID: m.Audio.FileID,
+			MimeType:       m.Audio.MimeType,
+			Size:           int64(m.Audio.FileSize),
+			Title:          m.Audio.Title,
+			Author:         m.Audio.Performer,
+			Duration:       int64(m.Audio.Duration),
+		}
+	case m.Voice != nil:
+		u = core.Upload{
+			TelegramFileID: m.Voice.FileID,
+			MimeType:       m.Voice.MimeType,
+			Size:           int64(m.Voice.FileSize),
+			Duration:       int64(m.Voice.Duration),
+		}
+	case m.Video != nil:
+		u = core.Upload{
+			TelegramFileID: m.Video.FileID,
+			MimeType:       m.Video.MimeType,
+			Size:           int64(m.Video.FileSize),
+			Duration:       int64(m.Video.Duration),
+		}
+	case m.Document != nil:
+		u = core.Upload{
+			TelegramFileID: m.Document.FileID,
+			FileName:       m.Document.FileName,
+			MimeType:       m.Document.MimeType,
+			Size:           int64(m.Document.FileSize),
+		}
+	default:
+		return core.Upload{}, false
+	}
+
+	//caption is a more deliberate title than file tags
+	if caption := strings.TrimSpace(m.Caption); caption != "" {
+		u.Title = strings.SplitN(caption, "\n", 2)[0]
+	}
+
+	return u, true
+}
+
+func (t *Telegram) handleUpload(user core.User, chatID int64, u core.Upload) {
+	//documents may be anything, media type is checked by ffprobe later, but obvious mismatches are rejected early
+	if u.MimeType != "" && !strings.HasPrefix(u.MimeType, "audio/") && !strings.HasPrefix(u.MimeType, "video/") {
+		t.Send(chatID, "Please send an audio or video file")
+		return
+	}
+
+	if u.Size > core.MaxUploadSize {
+		t.Send(chatID, fmt.Sprintf("The file is too large. Bots can download files up to %d MB", core.MaxUploadSize>>20))
+		return
+	}
+
+	t.dispatch(user, pendingItem{chatID: chatID, upload: &u})
+}
+
+func (t *Telegram) enqueueUpload(user core.User, chatID int64, feed string, u core.Upload) {
+	job, err := t.jobQueue.EnqueueUpload(user, chatID, feed, u)
+	if err != nil {
+		log.WithError(err).WithField("user", user.Username).Error("failed to enqueue upload")
+		t.SendInternalError(chatID)
+		return
+	}
+
+	t.sendStatus(job, fmt.Sprintf("Got the file, it will be in your feed soon. Job ID: %s", job.ID))
+}
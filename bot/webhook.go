@@ -0,0 +1,96 @@
+package bot
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"net/http"
+	"net/url"
+	"regexp"
+)
+
+const (
+	secretTokenHeader = "X-Telegram-Bot-Api-Secret-Token"
+)
+
+//telegram accepts only these characters in webhook secret token
+var webhookSecretRegexp = regexp.MustCompile(`^[A-Za-z0-9_-]{1,256}$`)
+
+//usePolling removes webhook, as Telegram does not allow getUpdates while it is set
+func (t *Telegram) usePolling() error {
+	if _, err := t.api.RemoveWebhook(); err != nil {
+		return errors.Wrap(err, "failed to remove webhook")
+	}
+
+	u := tgbotapi.NewUpdate(0)
+	u.Timeout = 60
+
+	updates, err := t.api.GetUpdatesChan(u)
+	if err != nil {
+		return errors.Wrap(err, "failed to get updates channel")
+	}
+	t.updates = updates
+
+	return nil
+}
+
+func (t *Telegram) useWebhook() error {
+	if !webhookSecretRegexp.MatchString(t.webhookSecret) {
+		return errors.New("webhook secret may contain only latin letters, digits, '_' and '-'")
+	}
+
+	t.webhookUpdates = make(chan tgbotapi.Update, 100)
+	t.updates = t.webhookUpdates
+
+	//tgbotapi.WebhookConfig has no secret token, so the request is made directly
+	resp, err := t.api.MakeRequest("setWebhook", url.Values{
+		"url":          {t.rootUrl + t.WebhookPath()},
+		"secret_token": {t.webhookSecret},
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to set webhook")
+	}
+	if !resp.Ok {
+		return errors.Errorf("failed to set webhook: %s", resp.Description)
+	}
+
+	return nil
+}
+
+//WebhookPath returns path Telegram posts updates to, empty if webhook mode is disabled.
+//The path is derived from the secret, so it cannot be guessed by the bot name or server url
+func (t *Telegram) WebhookPath() string {
+	if t.webhookSecret == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(t.webhookSecret))
+	return "/telegram/" + hex.EncodeToString(sum[:16])
+}
+
+//ServeWebhook accepts updates posted by Telegram and passes them to the same loop as long polling does
+func (t *Telegram) ServeWebhook(w http.ResponseWriter, r *http.Request) {
+	secret := r.Header.Get(secretTokenHeader)
+	if subtle.ConstantTimeCompare([]byte(secret), []byte(t.webhookSecret)) != 1 {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	var u tgbotapi.Update
+	if err := json.NewDecoder(r.Body).Decode(&u); err != nil {
+		log.WithError(err).Error("cannot decode webhook update")
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	//Telegram redelivers the update if it is not accepted in time
+	select {
+	case t.webhookUpdates <- u:
+		w.WriteHeader(http.StatusOK)
+	case <-r.Context().Done():
+		http.Error(w, "timeout", http.StatusServiceUnavailable)
+	}
+}
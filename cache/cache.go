@@ -37,3 +37,16 @@ func (c *LoadingCache) Get(key interface{}) (interface{}, bool) {
 func (c *LoadingCache) Add(key interface{}, value interface{}) {
 	c.lru.Add(key, value)
 }
+
+func (c *LoadingCache) Remove(key interface{}) {
+	c.lru.Remove(key)
+}
+
+//RemoveIf removes all entries whose key matches the predicate
+func (c *LoadingCache) RemoveIf(predicate func(key interface{}) bool) {
+	for _, key := range c.lru.Keys() {
+		if predicate(key) {
+			c.lru.Remove(key)
+		}
+	}
+}
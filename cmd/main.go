@@ -1,31 +1,70 @@
 package main
 
 import (
+	"github.com/htim/youpod/auth"
 	"github.com/htim/youpod/bot"
 	"github.com/htim/youpod/server"
 	"github.com/htim/youpod/server/handler"
 	"github.com/htim/youpod/service/media"
 	gdrive "github.com/htim/youpod/service/media/google_drive"
+	"github.com/htim/youpod/service/media/local"
+	"github.com/htim/youpod/service/media/s3"
+	"github.com/htim/youpod/service/processing"
+	"github.com/htim/youpod/service/queue"
 	"github.com/htim/youpod/service/rss"
+	"github.com/htim/youpod/service/source"
+	"github.com/htim/youpod/service/source/audio"
+	"github.com/htim/youpod/service/source/page"
+	"github.com/htim/youpod/service/source/podcast"
+	"github.com/htim/youpod/service/source/web"
+	"github.com/htim/youpod/service/subscription"
+	"github.com/htim/youpod/service/tagging"
+	"github.com/htim/youpod/service/upload"
 	"github.com/htim/youpod/service/youtube"
 	"github.com/htim/youpod/store/bolt"
 	"github.com/htim/youpod/store/mongo"
 	"github.com/jessevdk/go-flags"
 	log "github.com/sirupsen/logrus"
 	"os"
+	"time"
 )
 
 var opts struct {
-	ClientID     string `long:"client_id" env:"CLIENT_ID" description:"Google Drive client_id" required:"true"`
-	ClientSecret string `long:"client_secret" env:"CLIENT_SECRET" description:"Google Drive client secret" required:"true"`
+	Store         string `long:"store" env:"STORE" choice:"gdrive" choice:"local" choice:"s3" default:"gdrive" description:"where media files are kept"`
+	LocalStoreDir string `long:"local_store_dir" env:"LOCAL_STORE_DIR" default:"./media" description:"root directory for local store"`
+
+	S3 struct {
+		Endpoint   string        `long:"endpoint" env:"ENDPOINT" description:"s3 endpoint url"`
+		Bucket     string        `long:"bucket" env:"BUCKET" description:"s3 bucket"`
+		Region     string        `long:"region" env:"REGION" default:"us-east-1" description:"s3 region"`
+		AccessKey  string        `long:"access_key" env:"ACCESS_KEY" description:"s3 access key"`
+		SecretKey  string        `long:"secret_key" env:"SECRET_KEY" description:"s3 secret key"`
+		PathStyle  bool          `long:"path_style" env:"PATH_STYLE" description:"use path style bucket addressing (MinIO)"`
+		PresignTTL time.Duration `long:"presign_ttl" env:"PRESIGN_TTL" description:"redirect clients to pre-signed urls valid for given duration, disabled if empty"`
+	} `group:"s3" namespace:"s3" env-namespace:"S3"`
+
+	ClientID     string `long:"client_id" env:"CLIENT_ID" description:"Google Drive client_id, required for gdrive store" required:"false"`
+	ClientSecret string `long:"client_secret" env:"CLIENT_SECRET" description:"Google Drive client secret, required for gdrive store" required:"false"`
+
+	GDriveBlockSize int64 `long:"gdrive_block_size" env:"GDRIVE_BLOCK_SIZE" default:"4194304" description:"size of blocks google drive files are streamed by, in bytes"`
 
 	TelegramBotApiKey string `long:"tg_bot_api_key" env:"TG_BOT_API_KEY" description:"Telegram Bot API Key" required:"true"`
 
+	TelegramMode          string `long:"tg_mode" env:"TG_MODE" choice:"polling" choice:"webhook" default:"polling" description:"how telegram updates are received"`
+	TelegramWebhookSecret string `long:"tg_webhook_secret" env:"TG_WEBHOOK_SECRET" description:"secret token telegram sends with webhook requests, required for webhook mode"`
+
 	BaseURL          string `long:"base_url" env:"BASE_URL" description:"app base url" required:"true"`
 	BoltRootDir      string `long:"bolt_root_dir" env:"BOLT_ROOT_DIR" description:"directory for boltdb" required:"false"`
 	YoutubeOutputDir string `long:"youtube_output_dir" env:"YT_OUTPUT_DIR" description:"directory for youtube-dl" required:"false"`
 
 	MongoConnStr string `long:"mongo_conn_str" env:"MONGO_CONN_STR" description:"mongo connection string" required:"false"`
+
+	TokenKeys     []string `long:"token_key" env:"TOKEN_KEYS" env-delim:"," description:"keys oauth tokens are encrypted with as <key ID>:<base64 32 byte key>, the first one encrypts new tokens, tokens are kept in plain text if empty"`
+	MigrateTokens bool     `long:"migrate_tokens" env:"MIGRATE_TOKENS" description:"re-encrypt stored oauth tokens with the first token key and exit"`
+
+	Workers int `long:"workers" env:"WORKERS" default:"2" description:"number of concurrent download jobs"`
+
+	SubscriptionsInterval time.Duration `long:"subscriptions_interval" env:"SUBSCRIPTIONS_INTERVAL" default:"30m" description:"how often subscribed channels are checked for new videos"`
 }
 
 func main() {
@@ -62,16 +101,70 @@ func main() {
 		log.WithError(err).Fatal("cannot open mongo client")
 	}
 
-	userRepository := mongo.NewUserRepository(mongoClient)
+	var tokenCipher *auth.TokenCipher
+	if len(opts.TokenKeys) > 0 {
+		if tokenCipher, err = auth.NewTokenCipher(opts.TokenKeys); err != nil {
+			log.WithError(err).Fatal("cannot init token cipher")
+		}
+	}
 
-	googleDriveClient := gdrive.NewClient(
-		userRepository,
-		opts.ClientID,
-		opts.ClientSecret,
-		"http://localhost:9000"+"/gdrive/callback",
-	)
+	userRepository := mongo.NewUserRepository(mongoClient, tokenCipher)
+
+	if opts.MigrateTokens {
+		if tokenCipher == nil {
+			log.Fatal("token_key is required to migrate tokens")
+		}
+		if err := migrateTokens(userRepository); err != nil {
+			log.WithError(err).Fatal("cannot migrate tokens")
+		}
+		return
+	}
+
+	var store media.Store
+	var googleDriveAuth auth.OAuth2
+
+	switch opts.Store {
+	case "gdrive":
+		if opts.ClientID == "" || opts.ClientSecret == "" {
+			log.Fatal("client_id and client_secret are required for gdrive store")
+		}
+		googleDriveClient, err := gdrive.NewClient(
+			userRepository,
+			opts.ClientID,
+			opts.ClientSecret,
+			"http://localhost:9000"+"/gdrive/callback",
+			opts.GDriveBlockSize,
+		)
+		if err != nil {
+			log.WithError(err).Fatal("cannot init google drive client")
+		}
+		store = googleDriveClient
+		googleDriveAuth = googleDriveClient
+	case "local":
+		localStore, err := local.NewStore(opts.LocalStoreDir)
+		if err != nil {
+			log.WithError(err).Fatal("cannot init local store")
+		}
+		store = localStore
+	case "s3":
+		s3Store, err := s3.NewStore(s3.Config{
+			Endpoint:   opts.S3.Endpoint,
+			Bucket:     opts.S3.Bucket,
+			Region:     opts.S3.Region,
+			AccessKey:  opts.S3.AccessKey,
+			SecretKey:  opts.S3.SecretKey,
+			PathStyle:  opts.S3.PathStyle,
+			PresignTTL: opts.S3.PresignTTL,
+		})
+		if err != nil {
+			log.WithError(err).Fatal("cannot init s3 store")
+		}
+		store = s3Store
+	}
 
 	metadataRepository := mongo.NewMetadataRepository(mongoClient)
+	feedRepository := mongo.NewFeedRepository(mongoClient)
+	oauthStateRepository := mongo.NewOAuthStateRepository(mongoClient)
 
 	if opts.YoutubeOutputDir == "" {
 		opts.YoutubeOutputDir = "."
@@ -82,32 +175,102 @@ func main() {
 		log.WithError(err).Fatal("cannot init youtube service")
 	}
 
+	webClient, err := web.NewClient()
+	if err != nil {
+		log.WithError(err).Fatal("cannot init web client")
+	}
+
+	audioService, err := audio.NewService(webClient, opts.YoutubeOutputDir)
+	if err != nil {
+		log.WithError(err).Fatal("cannot init audio service")
+	}
+
+	//extractors are tried in order
+	sourceService, err := source.NewRegistry(
+		youtubeService,
+		audioService,
+		podcast.NewService(audioService, webClient),
+		page.NewService(audioService, webClient),
+	)
+	if err != nil {
+		log.WithError(err).Fatal("cannot init source registry")
+	}
+
 	rssService := rss.NewService(opts.BaseURL, metadataRepository)
 
 	mediaService := media.NewService(
 		metadataRepository,
-		googleDriveClient,
+		store,
+	)
+
+	uploadService, err := upload.NewService(opts.TelegramBotApiKey, opts.YoutubeOutputDir)
+	if err != nil {
+		log.WithError(err).Fatal("cannot init upload service")
+	}
+
+	processingService, err := processing.NewService(opts.YoutubeOutputDir)
+	if err != nil {
+		log.WithError(err).Fatal("cannot init processing service")
+	}
+
+	taggingService, err := tagging.NewService(opts.YoutubeOutputDir)
+	if err != nil {
+		log.WithError(err).Fatal("cannot init tagging service")
+	}
+
+	jobQueue := queue.NewService(
+		mongo.NewJobRepository(mongoClient),
+		userRepository,
+		feedRepository,
+		metadataRepository,
+		sourceService,
+		uploadService,
+		mediaService,
+		processingService,
+		taggingService,
+		opts.Workers,
+	)
+
+	scheduler := subscription.NewScheduler(
+		mongo.NewSubscriptionRepository(mongoClient),
+		userRepository,
+		sourceService,
+		jobQueue,
+		opts.SubscriptionsInterval,
 	)
 
+	webhookSecret := ""
+	if opts.TelegramMode == "webhook" {
+		if opts.TelegramWebhookSecret == "" {
+			log.Fatal("tg_webhook_secret is required for webhook mode")
+		}
+		webhookSecret = opts.TelegramWebhookSecret
+	}
+
 	tgBot, err := bot.NewTelegram(opts.TelegramBotApiKey,
 		userRepository,
-		youtubeService,
+		sourceService,
+		jobQueue,
 		mediaService,
 		rssService,
-		googleDriveClient,
+		feedRepository,
+		scheduler,
+		googleDriveAuth,
+		oauthStateRepository,
 		opts.BaseURL,
+		webhookSecret,
 	)
 
 	if err != nil {
 		log.WithError(err).Fatal("cannot init telegram bot")
 	}
 
-	tgBot.Run()
-
 	h, err := handler.NewHandler(userRepository,
+		feedRepository,
 		mediaService,
 		rssService,
-		googleDriveClient,
+		googleDriveAuth,
+		oauthStateRepository,
 		tgBot,
 	)
 
@@ -115,6 +278,16 @@ func main() {
 		log.WithError(err).Fatal("cannot init server handler")
 	}
 
+	//listeners are registered before anything runs, they are not guarded for concurrent registration
+	mediaService.OnDelete(h.EvictFile)
+	tgBot.OnRotate(h.EvictUser)
+
+	if err := jobQueue.Run(); err != nil {
+		log.WithError(err).Fatal("cannot run job queue")
+	}
+	scheduler.Run()
+	tgBot.Run()
+
 	srv := server.Server{
 		Handler: h,
 	}
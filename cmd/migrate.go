@@ -0,0 +1,31 @@
+package main
+
+import (
+	"context"
+	"github.com/htim/youpod/core"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+//migrateTokens re-saves every user with oauth token, so plain text tokens and tokens
+//encrypted with old keys are encrypted with the primary key
+func migrateTokens(userRepository core.UserRepository) error {
+	uu, err := userRepository.FindAllUsers(context.Background())
+	if err != nil {
+		return errors.Wrap(err, "cannot load users")
+	}
+
+	migrated := 0
+	for _, u := range uu {
+		if u.GDriveToken.AccessToken == "" && u.GDriveToken.RefreshToken == "" {
+			continue
+		}
+		if err := userRepository.UpdateGDriveToken(context.Background(), u); err != nil {
+			return errors.Wrapf(err, "cannot save user '%s'", u.Username)
+		}
+		migrated++
+	}
+
+	log.Infof("tokens of %d users are re-encrypted", migrated)
+	return nil
+}
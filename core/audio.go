@@ -0,0 +1,82 @@
+package core
+
+//Codecs files can be stored in
+const (
+	Mp3  = "mp3"
+	M4a  = "m4a" //AAC in MPEG-4 container
+	Opus = "opus"
+)
+
+//Bitrates users can choose from, in kbit/s
+var Bitrates = []int{64, 96, 128, 192}
+
+type AudioFormat struct {
+	Codec   string
+	Bitrate int //kbit/s, zero leaves the quality to the codec defaults
+}
+
+func ValidCodec(codec string) bool {
+	return codec == Mp3 || codec == M4a || codec == Opus
+}
+
+func ValidBitrate(bitrate int) bool {
+	if bitrate == 0 {
+		return true
+	}
+	for _, b := range Bitrates {
+		if b == bitrate {
+			return true
+		}
+	}
+	return false
+}
+
+//AudioFormat of new files, users who never changed it get mp3 as it plays everywhere
+func (u User) AudioFormat() AudioFormat {
+	f := AudioFormat{
+		Codec:   u.Codec,
+		Bitrate: u.Bitrate,
+	}
+	if !ValidCodec(f.Codec) {
+		f.Codec = Mp3
+	}
+	if !ValidBitrate(f.Bitrate) {
+		f.Bitrate = 0
+	}
+	return f
+}
+
+func (f AudioFormat) Extension() string {
+	return f.Codec
+}
+
+func (f AudioFormat) ContentType() string {
+	switch f.Codec {
+	case M4a:
+		return "audio/mp4"
+	case Opus:
+		return "audio/ogg"
+	default:
+		return "audio/mpeg"
+	}
+}
+
+//Extension of stored file by its content type, files saved before formats were introduced have none and are mp3
+func Extension(contentType string) string {
+	switch contentType {
+	case "audio/mp4":
+		return M4a
+	case "audio/ogg":
+		return Opus
+	default:
+		return Mp3
+	}
+}
+
+//ContentType of stored file, see Extension
+func ContentType(m Metadata) string {
+	if m.ContentType == "" {
+		return "audio/mpeg"
+	}
+	return m.ContentType
+}
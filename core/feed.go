@@ -0,0 +1,48 @@
+package core
+
+import (
+	"context"
+	"regexp"
+	"time"
+)
+
+//MainFeed is the name of the feed built from User.Files, it exists for every user
+const MainFeed = "main"
+
+var feedNameRegexp = regexp.MustCompile(`^[a-z0-9_-]{1,20}$`)
+
+type (
+	//Feed is an additional named feed of user, e.g. "talks" or "music"
+	Feed struct {
+		ID          string    `bson:"feed_id"`
+		Username    string    `bson:"username"`
+		Name        string    `bson:"name"` //url friendly name, unique per user
+		Title       string    `bson:"title"`
+		Description string    `bson:"description"`
+		Artwork     string    `bson:"artwork"` //image url
+		Files       []string  `bson:"files"`
+		CreatedAt   time.Time `bson:"created_at"`
+	}
+
+	FeedRepository interface {
+		//SaveFeed creates the feed or updates its details, files are changed by AddFileToFeed and RemoveFileFromFeed only
+		SaveFeed(ctx context.Context, f Feed) error
+		FindFeed(ctx context.Context, username, name string) (Feed, error)
+		FindFeedsByUser(ctx context.Context, username string) ([]Feed, error)
+		AddFileToFeed(ctx context.Context, f Feed, fileID string) error
+		RemoveFileFromFeed(ctx context.Context, f Feed, fileID string) error
+	}
+)
+
+func ValidFeedName(name string) bool {
+	return name != MainFeed && feedNameRegexp.MatchString(name)
+}
+
+func (f Feed) HasFile(fileID string) bool {
+	for _, id := range f.Files {
+		if id == fileID {
+			return true
+		}
+	}
+	return false
+}
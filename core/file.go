@@ -6,5 +6,7 @@ type (
 	File struct {
 		Metadata
 		Content io.ReadCloser
+		//Transcript is stored along with content when available
+		Transcript []Cue
 	}
 )
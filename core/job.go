@@ -0,0 +1,64 @@
+package core
+
+import (
+	"context"
+	"time"
+)
+
+type (
+	JobState string
+
+	//Job is a single link sent by user which is processed asynchronously by job queue
+	Job struct {
+		ID        string    `bson:"job_id"`
+		Username  string    `bson:"username"`
+		ChatID    int64     `bson:"chat_id"`
+		Link      string    `bson:"link"`
+		Playlist  string    `bson:"playlist"` //title of playlist the job was expanded from
+		Feed      string    `bson:"feed"`     //name of the feed file is added to
+		Upload    *Upload   `bson:"upload"`   //set instead of link for files sent to the bot
+		State     JobState  `bson:"state"`
+		Title     string    `bson:"title"`
+		FileID    string    `bson:"file_id"`
+		Error     string    `bson:"error"`
+		CreatedAt time.Time `bson:"created_at"`
+		UpdatedAt time.Time `bson:"updated_at"`
+
+		//Done and Total are bytes of the current state, they are published with updates and not persisted
+		Done  int64 `bson:"-" json:"-"`
+		Total int64 `bson:"-" json:"-"`
+	}
+
+	JobRepository interface {
+		SaveJob(ctx context.Context, j Job) error
+		GetJob(ctx context.Context, ID string) (Job, error)
+		FindJobsByState(ctx context.Context, states ...JobState) ([]Job, error)
+	}
+
+	JobQueue interface {
+		Enqueue(owner User, chatID int64, feed string, link string) (Job, error)
+		//EnqueuePlaylist creates separate job for every playlist entry
+		EnqueuePlaylist(owner User, chatID int64, feed string, p Playlist) ([]Job, error)
+		EnqueueUpload(owner User, chatID int64, feed string, u Upload) (Job, error)
+		//Cancel aborts unfinished jobs of the owner and returns them
+		Cancel(owner User) []Job
+		//Updates returns channel with jobs whose state or progress has been changed
+		Updates() <-chan Job
+	}
+)
+
+const (
+	JobQueued      JobState = "queued"
+	JobDownloading JobState = "downloading"
+	JobProcessing  JobState = "processing"
+	JobUploading   JobState = "uploading"
+	JobDone        JobState = "done"
+	JobFailed      JobState = "failed"
+	//JobDuplicate is finished without downloading, FileID is the file user already has
+	JobDuplicate JobState = "duplicate"
+	JobCancelled JobState = "cancelled"
+)
+
+func (j Job) IsFinished() bool {
+	return j.State == JobDone || j.State == JobFailed || j.State == JobDuplicate || j.State == JobCancelled
+}
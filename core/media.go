@@ -7,8 +7,11 @@ import (
 
 type (
 	MediaService interface {
-		SaveFile(u User, f File) (string, error)
-		GetFileContent(u User, fileID string) (io.ReadSeeker, error)
-		GetFileMetadata(user User, fileID string, ctx context.Context) (Metadata, error)
+		SaveFile(ctx context.Context, u User, f File) (string, error)
+		GetFileContent(ctx context.Context, u User, fileID string) (io.ReadSeeker, error)
+		//GetFileURL returns url for downloading file directly from the store, empty if it is not supported
+		GetFileURL(ctx context.Context, u User, fileID string) (string, error)
+		GetFileMetadata(ctx context.Context, u User, fileID string) (Metadata, error)
+		DeleteFile(ctx context.Context, u User, fileID string) error
 	}
 )
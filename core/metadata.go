@@ -8,6 +8,7 @@ import (
 type (
 	Metadata struct {
 		FileID      string    `bson:"file_id"`
+		Username    string    `bson:"username"` //owner of the file
 		TmpFileID   string    `bson:"tmp_file_id"`
 		Name        string    `bson:"name"`
 		ContentType string    `bson:"content_type"`
@@ -15,10 +16,30 @@ type (
 		Size        int64     `bson:"size"`    //size in bytes
 		Picture     string    `bson:"picture"` //base64
 		CreatedAt   time.Time `bson:"created_at"`
+		UploadDate  time.Time `bson:"upload_date"` //original upload date, zero if unknown
+		Duration    int64     `bson:"duration"`    //duration in seconds
+		Source      string    `bson:"source"`      //name of the extractor the file came from
+		URL         string    `bson:"url"`         //link the file was downloaded from, empty for uploads
+		SourceID    string    `bson:"source_id"`   //canonical ID of the original, e.g. 'youtube:<video ID>', empty if unknown
+		ChannelURL  string    `bson:"channel_url"` //link to the author's channel, empty if unknown
+		Description string    `bson:"description"` //plain text description of the original
+		Processing  []string  `bson:"processing"`  //post-processing steps applied to the file
+		Chapters    []Chapter `bson:"chapters"`
+		//Transcripts are store file IDs of transcript by format
+		Transcripts map[string]string `bson:"transcripts"`
+	}
+
+	Chapter struct {
+		Title string  `bson:"title"`
+		Start float64 `bson:"start"` //seconds
+		End   float64 `bson:"end"`   //seconds
 	}
 
 	MetadataRepository interface {
 		GetFileMetadata(ctx context.Context, ID string) (m Metadata, err error)
 		SaveFileMetadata(ctx context.Context, m Metadata) (err error)
+		DeleteFileMetadata(ctx context.Context, ID string) (err error)
+		//FindFileMetadataBySource returns file of the user downloaded from the same original
+		FindFileMetadataBySource(ctx context.Context, username, sourceID string) (m Metadata, err error)
 	}
 )
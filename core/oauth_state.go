@@ -0,0 +1,25 @@
+package core
+
+import (
+	"context"
+	"time"
+)
+
+type (
+	//OAuthState binds OAuth2 state parameter to telegram user who requested authorization
+	OAuthState struct {
+		State      string    `bson:"state"`
+		TelegramID int64     `bson:"telegram_id"`
+		ExpiresAt  time.Time `bson:"expires_at"`
+	}
+
+	OAuthStateRepository interface {
+		SaveState(ctx context.Context, s OAuthState) error
+		//TakeState returns state and deletes it, so every state can be used only once
+		TakeState(ctx context.Context, state string) (OAuthState, error)
+	}
+)
+
+func (s OAuthState) IsExpired() bool {
+	return time.Now().After(s.ExpiresAt)
+}
@@ -0,0 +1,47 @@
+package core
+
+import "context"
+
+//Speeds users can choose from, 1 keeps the original speed
+var Speeds = []float64{1, 1.25, 1.5, 1.75, 2}
+
+type (
+	Processing struct {
+		Normalize   bool    //EBU R128 loudness normalization
+		TrimSilence bool    //remove dead air at start and end
+		Speed       float64 //playback speed multiplier
+	}
+
+	//ProcessingService post-processes downloaded files before they are stored
+	ProcessingService interface {
+		Process(ctx context.Context, owner User, f File) (File, error)
+		Cleanup(f File)
+	}
+)
+
+func ValidSpeed(speed float64) bool {
+	for _, s := range Speeds {
+		if s == speed {
+			return true
+		}
+	}
+	return false
+}
+
+//Processing of new files, zero speed of users who never changed it means the original one
+func (u User) Processing() Processing {
+	p := Processing{
+		Normalize:   u.Normalize,
+		TrimSilence: u.TrimSilence,
+		Speed:       u.Speed,
+	}
+	if !ValidSpeed(p.Speed) {
+		p.Speed = 1
+	}
+	return p
+}
+
+//Empty is true when there is nothing to do with files
+func (p Processing) Empty() bool {
+	return !p.Normalize && !p.TrimSilence && p.Speed == 1
+}
@@ -0,0 +1,30 @@
+package core
+
+import "io"
+
+//Progress receives bytes done out of total of a long running stage, total is 0 if unknown
+type Progress func(done, total int64)
+
+//Reader reports bytes read from rc, nil progress returns rc as is
+func (p Progress) Reader(rc io.ReadCloser, total int64) io.ReadCloser {
+	if p == nil {
+		return rc
+	}
+	return &progressReader{ReadCloser: rc, total: total, progress: p}
+}
+
+type progressReader struct {
+	io.ReadCloser
+	done     int64
+	total    int64
+	progress Progress
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 {
+		r.done += int64(n)
+		r.progress(r.done, r.total)
+	}
+	return n, err
+}
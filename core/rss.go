@@ -1,8 +1,14 @@
 package core
 
+import "context"
+
 type (
 	RssService interface {
 		UserFeedUrl(user User) string
-		UserFeed(user User) (string, error)
+		UserFeed(ctx context.Context, user User) (string, error)
+		FeedUrl(user User, feed Feed) string
+		Feed(ctx context.Context, user User, feed Feed) (string, error)
+		//Chapters renders file chapters in Podcasting 2.0 JSON chapters format
+		Chapters(m Metadata) ([]byte, error)
 	}
 )
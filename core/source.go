@@ -0,0 +1,50 @@
+package core
+
+import "context"
+
+type (
+	//SourceExtractor turns links of a particular kind into audio files.
+	//Handles may inspect the link over network, so it should not be called from latency sensitive code
+	SourceExtractor interface {
+		Name() string
+		Handles(ctx context.Context, link string) bool
+		//Download reports bytes downloaded to progress when possible
+		Download(ctx context.Context, owner User, link string, progress Progress) (File, error)
+		Cleanup(f File)
+	}
+
+	//PlaylistExtractor is implemented by extractors of links pointing to several items, like channels or podcast feeds
+	PlaylistExtractor interface {
+		IsPlaylist(link string) bool
+		Playlist(ctx context.Context, link string) (Playlist, error)
+	}
+
+	//IdentifyingExtractor is implemented by extractors able to tell what the link points to without downloading it
+	IdentifyingExtractor interface {
+		//ID returns canonical ID of the item, the same for all links pointing to it
+		ID(ctx context.Context, link string) (string, error)
+	}
+
+	//SourceService dispatches links to the first registered extractor handling them
+	SourceService interface {
+		Handles(ctx context.Context, link string) bool
+		Download(ctx context.Context, owner User, link string, progress Progress) (File, error)
+		Cleanup(f File)
+		IsPlaylist(ctx context.Context, link string) bool
+		Playlist(ctx context.Context, link string) (Playlist, error)
+		//ID returns canonical ID of the item prefixed with extractor name, empty if extractor cannot identify it
+		ID(ctx context.Context, link string) (string, error)
+	}
+
+	Playlist struct {
+		ID      string
+		Title   string
+		Entries []PlaylistEntry
+	}
+
+	PlaylistEntry struct {
+		ID    string
+		Title string
+		Link  string
+	}
+)
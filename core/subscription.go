@@ -0,0 +1,45 @@
+package core
+
+import (
+	"context"
+	"time"
+)
+
+type (
+	//Subscription binds user feed to youtube channel or playlist, new uploads are added to the feed automatically
+	Subscription struct {
+		ID        string    `bson:"subscription_id"`
+		Username  string    `bson:"username"`
+		ChatID    int64     `bson:"chat_id"`
+		Link      string    `bson:"link"`
+		Title     string    `bson:"title"`
+		Feed      string    `bson:"feed"`
+		SeenIDs   []string  `bson:"seen_ids"` //ids of videos which are already processed
+		CreatedAt time.Time `bson:"created_at"`
+		CheckedAt time.Time `bson:"checked_at"`
+	}
+
+	SubscriptionRepository interface {
+		SaveSubscription(ctx context.Context, s Subscription) error
+		//UpdateChecked saves seen ids and check time of the subscription unless it has been deleted meanwhile
+		UpdateChecked(ctx context.Context, s Subscription) error
+		DeleteSubscription(ctx context.Context, ID string) error
+		FindSubscriptionsByUser(ctx context.Context, username string) ([]Subscription, error)
+		FindAllSubscriptions(ctx context.Context) ([]Subscription, error)
+	}
+
+	SubscriptionService interface {
+		Subscribe(owner User, chatID int64, feed string, link string) (Subscription, error)
+		Unsubscribe(owner User, ID string) error
+		Subscriptions(owner User) ([]Subscription, error)
+	}
+)
+
+func (s Subscription) Seen(videoID string) bool {
+	for _, id := range s.SeenIDs {
+		if id == videoID {
+			return true
+		}
+	}
+	return false
+}
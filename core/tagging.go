@@ -0,0 +1,12 @@
+package core
+
+import "context"
+
+type (
+	//TaggingService writes metadata into files before they are stored
+	TaggingService interface {
+		//Tag uses title of the feed file goes to as album
+		Tag(ctx context.Context, owner User, feed Feed, f File) (File, error)
+		Cleanup(f File)
+	}
+)
@@ -0,0 +1,25 @@
+package core
+
+//Formats transcripts are published in
+const (
+	VTT = "vtt"
+	SRT = "srt"
+)
+
+//TranscriptFormats lists formats in the order they are listed in feeds
+var TranscriptFormats = []string{VTT, SRT}
+
+//Cue is a piece of transcript text shown between Start and End seconds
+type Cue struct {
+	Start float64
+	End   float64
+	Text  string
+}
+
+//TranscriptType is the content type of transcript format
+func TranscriptType(format string) string {
+	if format == SRT {
+		return "application/srt"
+	}
+	return "text/vtt"
+}
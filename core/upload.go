@@ -0,0 +1,25 @@
+package core
+
+import "context"
+
+//MaxUploadSize is the largest file Telegram Bot API allows bots to download
+const MaxUploadSize = 20 << 20
+
+type (
+	//Upload is an audio or video file sent to the bot directly instead of a link
+	Upload struct {
+		TelegramFileID string `bson:"telegram_file_id"`
+		FileName       string `bson:"file_name"`
+		MimeType       string `bson:"mime_type"`
+		Size           int64  `bson:"size"`
+		Title          string `bson:"title"`    //from message caption or audio title, file tags are used if empty
+		Author         string `bson:"author"`   //from audio performer, file tags are used if empty
+		Duration       int64  `bson:"duration"` //seconds
+	}
+
+	UploadService interface {
+		//Import downloads uploaded file and converts it to the feed audio format
+		Import(ctx context.Context, owner User, u Upload) (File, error)
+		Cleanup(f File)
+	}
+)
@@ -14,14 +14,42 @@ type (
 
 		FeedUrl string `bson:"feed_url"`
 
-		//list of file ids uploaded by user
+		//secret part of feed and file urls, so they cannot be guessed by username
+		Token string `bson:"token"`
+
+		//list of file ids in the main feed of user
 		Files []string `bson:"files"`
+
+		//feed new links are added to, user is asked to choose a feed if empty
+		DefaultFeed string `bson:"default_feed"`
+
+		//format new files are converted to, see AudioFormat
+		Codec   string `bson:"codec"`
+		Bitrate int    `bson:"bitrate"`
+
+		//post-processing of new files, see Processing
+		Normalize   bool    `bson:"normalize"`
+		TrimSilence bool    `bson:"trim_silence"`
+		Speed       float64 `bson:"speed"`
+
+		//language code of subtitles published as transcripts, transcripts are disabled if empty
+		TranscriptLanguage string `bson:"transcript_language"`
 	}
 
 	UserRepository interface {
+		//SaveUser creates the user or updates all its fields but files
 		SaveUser(ctx context.Context, u User) error
+		//UpdateToken changes only the feed token of the stored user
+		UpdateToken(ctx context.Context, u User) error
+		//UpdateGDriveToken encrypts and changes only the Google Drive token of the stored user
+		UpdateGDriveToken(ctx context.Context, u User) error
+		//UpdateSettings changes only default feed, format, processing and transcript settings of the stored user,
+		//so a stale copy of the user does not undo concurrent changes
+		UpdateSettings(ctx context.Context, u User) error
 		FindUserByUsername(ctx context.Context, username string) (User, error)
 		FindUserByTelegramID(ctx context.Context, id int64) (User, error)
+		FindAllUsers(ctx context.Context) ([]User, error)
 		AddFileToUser(ctx context.Context, u User, fileID string) error
+		RemoveFileFromUser(ctx context.Context, u User, fileID string) error
 	}
 )
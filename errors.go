@@ -6,4 +6,12 @@ var (
 	ErrUserNotFound     = errors.New("user not found")
 	ErrFileNotFound     = errors.New("file not found")
 	ErrMetadataNotFound = errors.New("file metadata not found")
+	ErrJobNotFound      = errors.New("job not found")
+
+	ErrSubscriptionNotFound = errors.New("subscription not found")
+	ErrFeedNotFound         = errors.New("feed not found")
+	ErrFeedExists           = errors.New("feed already exists")
+	ErrFileExists           = errors.New("file already exists")
+	ErrNotPlaylist          = errors.New("link is not a playlist or channel")
+	ErrStateNotFound        = errors.New("oauth state not found")
 )
@@ -1,32 +1,46 @@
 package handler
 
 import (
-	context2 "context"
 	"fmt"
 	"github.com/htim/youpod"
 	log "github.com/sirupsen/logrus"
-	"golang.org/x/net/context"
 	"net/http"
-	"strconv"
 )
 
 func (h *Handler) gdriveAuthCallback(w http.ResponseWriter, r *http.Request) {
 	code := r.URL.Query().Get("code")
 	state := r.URL.Query().Get("state")
 
-	token, err := h.googleDriveAuth.Exchange(code)
+	if r.URL.Query().Get("error") != "" {
+		http.Error(w, "Google Drive access was not granted. Send any message to the bot to get a new login link", http.StatusBadRequest)
+		return
+	}
+
+	//state is checked before the code exchange and is deleted on first use, so the link cannot be replayed
+	s, err := h.oauthStateRepository.TakeState(r.Context(), state)
 	if err != nil {
+		if err == youpod.ErrStateNotFound {
+			http.Error(w, "This login link is invalid or has already been used. Send any message to the bot to get a new one", http.StatusBadRequest)
+			return
+		}
+
+		log.WithError(err).Error("cannot find oauth state")
 		http.Error(w, "internal error", http.StatusInternalServerError)
 		return
 	}
 
-	telegramID, err := strconv.Atoi(state)
+	if s.IsExpired() {
+		http.Error(w, "This login link has expired. Send any message to the bot to get a new one", http.StatusBadRequest)
+		return
+	}
+
+	token, err := h.googleDriveAuth.Exchange(code)
 	if err != nil {
-		http.Error(w, "unparseable state", http.StatusBadRequest)
+		http.Error(w, "internal error", http.StatusInternalServerError)
 		return
 	}
 
-	user, err := h.userService.FindUserByTelegramID(context2.Background(), int64(telegramID))
+	user, err := h.userService.FindUserByTelegramID(r.Context(), s.TelegramID)
 	if err != nil {
 		if err == youpod.ErrUserNotFound {
 			http.Error(w, "user not found", http.StatusNotFound)
@@ -47,7 +61,7 @@ func (h *Handler) gdriveAuthCallback(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err = h.userService.SaveUser(context.Background(), user); err != nil {
+	if err = h.userService.UpdateGDriveToken(r.Context(), user); err != nil {
 		log.WithError(err).Error("cannot update user")
 		http.Error(w, "internal error", http.StatusInternalServerError)
 		return
@@ -16,22 +16,26 @@ const (
 )
 
 type Handler struct {
-	userService  core.UserRepository
-	rssService   core.RssService
-	mediaService core.MediaService
+	userService    core.UserRepository
+	feedRepository core.FeedRepository
+	rssService     core.RssService
+	mediaService   core.MediaService
 
-	googleDriveAuth auth.OAuth2
-	bot             *bot.Telegram
+	googleDriveAuth      auth.OAuth2
+	oauthStateRepository core.OAuthStateRepository
+	bot                  *bot.Telegram
 
 	responseCache *cache.LoadingCache
 }
 
 func NewHandler(
 	userService core.UserRepository,
+	feedRepository core.FeedRepository,
 	mediaService core.MediaService,
 	rss core.RssService,
 
 	googleDriveAuth auth.OAuth2,
+	oauthStateRepository core.OAuthStateRepository,
 	bot *bot.Telegram,
 ) (*Handler, error) {
 	rspCache, err := cache.NewLoadingCache()
@@ -40,11 +44,13 @@ func NewHandler(
 	}
 
 	handler := &Handler{
-		userService:     userService,
-		mediaService:    mediaService,
-		googleDriveAuth: googleDriveAuth,
-		bot:             bot,
-		rssService:      rss,
+		userService:          userService,
+		feedRepository:       feedRepository,
+		mediaService:         mediaService,
+		googleDriveAuth:      googleDriveAuth,
+		oauthStateRepository: oauthStateRepository,
+		bot:                  bot,
+		rssService:           rss,
 
 		responseCache: rspCache,
 	}
@@ -59,13 +65,23 @@ func (h *Handler) Routes() chi.Router {
 	r.Use(middleware.Recoverer)
 	r.Use(middleware.Logger)
 
-	r.Get("/gdrive/callback", h.gdriveAuthCallback)
+	if h.googleDriveAuth != nil {
+		r.Get("/gdrive/callback", h.gdriveAuthCallback)
+	}
+
+	if path := h.bot.WebhookPath(); path != "" {
+		r.Post(path, h.bot.ServeWebhook)
+	}
 
-	r.Head("/feed/{username}", h.headCheck)
-	r.Get("/feed/{username}", h.rssFeed)
+	r.Head("/feed/{username}/{token}", h.headCheck)
+	r.Get("/feed/{username}/{token}", h.rssFeed)
+	r.Head("/feed/{username}/{token}/{feed}", h.headCheck)
+	r.Get("/feed/{username}/{token}/{feed}", h.namedFeed)
 
-	r.Get("/files/{username}/{fileID}.mp3", h.serveFile)
-	r.Get("/files/{username}/{fileID}/thumbnail.jpg", h.serveFileThumbnail)
+	r.Get("/files/{username}/{token}/{fileID}.{ext}", h.serveFile)
+	r.Get("/files/{username}/{token}/{fileID}/thumbnail.jpg", h.serveFileThumbnail)
+	r.Get("/files/{username}/{token}/{fileID}/chapters.json", h.serveFileChapters)
+	r.Get("/files/{username}/{token}/{fileID}/transcript.{format}", h.serveFileTranscript)
 
 	r.Mount("/", http.FileServer(http.Dir("./assets")))
 
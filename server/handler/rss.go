@@ -1,7 +1,6 @@
 package handler
 
 import (
-	context2 "context"
 	"fmt"
 	"github.com/go-chi/chi"
 	"github.com/htim/youpod"
@@ -9,27 +8,54 @@ import (
 	"net/http"
 )
 
-//GET /feed/{username}
+//GET /feed/{username}/{token}
 func (h *Handler) rssFeed(w http.ResponseWriter, r *http.Request) {
 
-	username := chi.URLParam(r, "username")
+	user, ok := h.authorizedUser(w, r)
+	if !ok {
+		return
+	}
 
-	user, err := h.userService.FindUserByUsername(context2.Background(), username)
+	feed, err := h.rssService.UserFeed(r.Context(), user)
 	if err != nil {
+		log.WithError(err).WithField("user", user.Username).Error("cannot generate feed")
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	if _, err = fmt.Fprint(w, feed); err != nil {
+		log.WithError(err).WithField("user", user.Username).Error("cannot send feed")
+		http.Error(w, "internal error", http.StatusInternalServerError)
+	}
 
-		if err == youpod.ErrUserNotFound {
-			http.Error(w, "user not found", http.StatusNotFound)
+}
+
+//GET /feed/{username}/{token}/{feed}
+func (h *Handler) namedFeed(w http.ResponseWriter, r *http.Request) {
+
+	name := chi.URLParam(r, "feed")
+
+	user, ok := h.authorizedUser(w, r)
+	if !ok {
+		return
+	}
+
+	f, err := h.feedRepository.FindFeed(r.Context(), user.Username, name)
+	if err != nil {
+
+		if err == youpod.ErrFeedNotFound {
+			http.Error(w, "feed not found", http.StatusNotFound)
 			return
 		}
 
-		log.WithError(err).Error("cannot find user")
+		log.WithError(err).WithField("user", user.Username).Error("cannot find feed")
 		http.Error(w, "internal error", http.StatusInternalServerError)
 		return
 	}
 
-	feed, err := h.rssService.UserFeed(user)
+	feed, err := h.rssService.Feed(r.Context(), user, f)
 	if err != nil {
-		log.WithError(err).WithField("user", user.Username).Error("cannot generate feed")
+		log.WithError(err).WithField("user", user.Username).WithField("feed", name).Error("cannot generate feed")
 		http.Error(w, "internal error", http.StatusInternalServerError)
 		return
 	}
@@ -2,12 +2,13 @@ package handler
 
 import (
 	"bytes"
-	context2 "context"
+	"context"
 	"encoding/base64"
 	"github.com/go-chi/chi"
 	"github.com/htim/youpod"
+	"github.com/htim/youpod/core"
+	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
-	"golang.org/x/net/context"
 	"io"
 	"net/http"
 	"time"
@@ -18,17 +19,97 @@ type fileKey struct {
 	fileID   string
 }
 
+//fileValue is cached between requests, content readers are opened per request as they are not safe for concurrent use
 type fileValue struct {
-	rs   io.ReadSeeker
-	name string
+	user        core.User
+	name        string
+	contentType string
 }
 
-//HEAD /files/{username}/{fileID}.mp3
+//EvictFile removes cached response for deleted file
+func (h *Handler) EvictFile(user core.User, fileID string) {
+	h.responseCache.Remove(fileKey{
+		username: user.Username,
+		fileID:   fileID,
+	})
+}
+
+//HEAD /feed/{username}/{token}[/{feed}]
 func (h *Handler) headCheck(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.authorizedUser(w, r)
+	if !ok {
+		return
+	}
+
+	if name := chi.URLParam(r, "feed"); name != "" {
+		if _, err := h.feedRepository.FindFeed(r.Context(), user.Username, name); err != nil {
+			if err == youpod.ErrFeedNotFound {
+				http.Error(w, "feed not found", http.StatusNotFound)
+				return
+			}
+			log.WithError(err).WithField("user", user.Username).Error("cannot find feed")
+			http.Error(w, InternalErrorMessage, http.StatusInternalServerError)
+			return
+		}
+	}
+
 	w.WriteHeader(http.StatusOK)
 }
 
-//GET /files/{username}/{fileID}.mp3
+//fileMetadata loads metadata of a file owned by the user. Files of other users are not found,
+//error response is written if false is returned
+func (h *Handler) fileMetadata(w http.ResponseWriter, r *http.Request, user core.User, fileID string) (core.Metadata, bool) {
+	metadata, err := h.mediaService.GetFileMetadata(r.Context(), user, fileID)
+	if err != nil {
+		if errors.Cause(err) == youpod.ErrMetadataNotFound {
+			http.Error(w, "not found", http.StatusNotFound)
+			return core.Metadata{}, false
+		}
+		log.WithError(err).Error("failed to get file metadata")
+		http.Error(w, InternalErrorMessage, http.StatusInternalServerError)
+		return core.Metadata{}, false
+	}
+
+	owned, err := h.owns(r.Context(), user, metadata)
+	if err != nil {
+		log.WithError(err).WithField("user", user.Username).Error("failed to check file owner")
+		http.Error(w, InternalErrorMessage, http.StatusInternalServerError)
+		return core.Metadata{}, false
+	}
+	if !owned {
+		http.Error(w, "not found", http.StatusNotFound)
+		return core.Metadata{}, false
+	}
+
+	return metadata, true
+}
+
+//owns checks owner of the file. Files saved before metadata got owner are looked up in feeds of the user
+func (h *Handler) owns(ctx context.Context, user core.User, metadata core.Metadata) (bool, error) {
+	if metadata.Username != "" {
+		return metadata.Username == user.Username, nil
+	}
+
+	for _, id := range user.Files {
+		if id == metadata.FileID {
+			return true, nil
+		}
+	}
+
+	ff, err := h.feedRepository.FindFeedsByUser(ctx, user.Username)
+	if err != nil {
+		return false, err
+	}
+	for _, f := range ff {
+		if f.HasFile(metadata.FileID) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+//GET /files/{username}/{token}/{fileID}.{ext}
 func (h *Handler) serveFile(w http.ResponseWriter, r *http.Request) {
 
 	username := chi.URLParam(r, "username")
@@ -47,73 +128,85 @@ func (h *Handler) serveFile(w http.ResponseWriter, r *http.Request) {
 
 		f = cached.(fileValue)
 
-	} else {
-
-		user, err := h.userService.FindUserByUsername(context2.Background(), username)
+		if !validToken(f.user, chi.URLParam(r, "token")) {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
 
-		if err != nil {
+	} else {
 
-			if err == youpod.ErrUserNotFound {
-				http.Error(w, "user not found", http.StatusNotFound)
-				return
-			}
+		user, ok := h.authorizedUser(w, r)
+		if !ok {
+			return
+		}
 
-			log.WithError(err).WithField("username", username).Error("failed to find user")
-			http.Error(w, InternalErrorMessage, http.StatusInternalServerError)
+		metadata, ok := h.fileMetadata(w, r, user, fileID)
+		if !ok {
 			return
 		}
 
-		metadata, err := h.mediaService.GetFileMetadata(user, fileID, context.Background())
+		url, err := h.mediaService.GetFileURL(r.Context(), user, fileID)
 		if err != nil {
-			log.WithError(err).Error("failed to get rs metadata")
+			log.WithError(err).Error("failed to get file url")
 			http.Error(w, InternalErrorMessage, http.StatusInternalServerError)
 			return
 		}
 
-		rs, err := h.mediaService.GetFileContent(user, fileID)
-
-		if err != nil {
-			log.WithError(err).Error("failed to get rs content")
-			http.Error(w, InternalErrorMessage, http.StatusInternalServerError)
+		if url != "" {
+			http.Redirect(w, r, url, http.StatusFound)
 			return
 		}
 
 		f = fileValue{
-			rs:   rs,
-			name: metadata.Name,
+			user:        user,
+			name:        metadata.Name,
+			contentType: core.ContentType(metadata),
 		}
 
 		h.responseCache.Add(fk, f)
 	}
 
-	w.Header().Set("Content-Type", "audio/mpeg")
-	http.ServeContent(w, r, f.name, time.Time{}, f.rs)
+	if chi.URLParam(r, "ext") != core.Extension(f.contentType) {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	rs, err := h.mediaService.GetFileContent(r.Context(), f.user, fileID)
+	if err != nil {
+		log.WithError(err).Error("failed to get rs content")
+		http.Error(w, InternalErrorMessage, http.StatusInternalServerError)
+		return
+	}
+
+	if c, ok := rs.(io.Closer); ok {
+		defer func() {
+			if err := c.Close(); err != nil {
+				log.WithError(err).Error("failed to close file content")
+			}
+		}()
+	}
+
+	w.Header().Set("Content-Type", f.contentType)
+	http.ServeContent(w, r, f.name, time.Time{}, rs)
 
 }
 
-//GET /files/{username}/{fileID}/thumbnail.jpg
+//GET /files/{username}/{token}/{fileID}/thumbnail.jpg
 func (h *Handler) serveFileThumbnail(w http.ResponseWriter, r *http.Request) {
-	username := chi.URLParam(r, "username")
 	fileID := chi.URLParam(r, "fileID")
 
-	user, err := h.userService.FindUserByUsername(context2.Background(), username)
-
-	if err != nil {
-
-		if err == youpod.ErrUserNotFound {
-			http.Error(w, "user not found", http.StatusNotFound)
-			return
-		}
+	user, ok := h.authorizedUser(w, r)
+	if !ok {
+		return
+	}
 
-		log.WithError(err).WithField("username", username).Error("failed to find user")
-		http.Error(w, InternalErrorMessage, http.StatusInternalServerError)
+	metadata, ok := h.fileMetadata(w, r, user, fileID)
+	if !ok {
 		return
 	}
 
-	metadata, err := h.mediaService.GetFileMetadata(user, fileID, context.Background())
-	if err != nil {
-		log.WithError(err).Error("failed to get file metadata")
-		http.Error(w, InternalErrorMessage, http.StatusInternalServerError)
+	if metadata.Picture == "" {
+		http.Error(w, "not found", http.StatusNotFound)
 		return
 	}
 
@@ -133,3 +226,76 @@ func (h *Handler) serveFileThumbnail(w http.ResponseWriter, r *http.Request) {
 	}
 
 }
+
+//GET /files/{username}/{token}/{fileID}/chapters.json
+func (h *Handler) serveFileChapters(w http.ResponseWriter, r *http.Request) {
+	fileID := chi.URLParam(r, "fileID")
+
+	user, ok := h.authorizedUser(w, r)
+	if !ok {
+		return
+	}
+
+	metadata, ok := h.fileMetadata(w, r, user, fileID)
+	if !ok {
+		return
+	}
+
+	if len(metadata.Chapters) == 0 {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	bb, err := h.rssService.Chapters(metadata)
+	if err != nil {
+		log.WithError(err).Error("failed to render chapters")
+		http.Error(w, InternalErrorMessage, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json+chapters")
+
+	if _, err = w.Write(bb); err != nil {
+		log.WithError(err).Error("cannot serve chapters")
+	}
+}
+
+//GET /files/{username}/{token}/{fileID}/transcript.{format}
+func (h *Handler) serveFileTranscript(w http.ResponseWriter, r *http.Request) {
+	fileID := chi.URLParam(r, "fileID")
+	format := chi.URLParam(r, "format")
+
+	user, ok := h.authorizedUser(w, r)
+	if !ok {
+		return
+	}
+
+	metadata, ok := h.fileMetadata(w, r, user, fileID)
+	if !ok {
+		return
+	}
+
+	transcriptID, ok := metadata.Transcripts[format]
+	if !ok {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	rs, err := h.mediaService.GetFileContent(r.Context(), user, transcriptID)
+	if err != nil {
+		log.WithError(err).Error("failed to get transcript content")
+		http.Error(w, InternalErrorMessage, http.StatusInternalServerError)
+		return
+	}
+
+	if c, ok := rs.(io.Closer); ok {
+		defer func() {
+			if err := c.Close(); err != nil {
+				log.WithError(err).Error("failed to close transcript content")
+			}
+		}()
+	}
+
+	w.Header().Set("Content-Type", core.TranscriptType(format)+"; charset=utf-8")
+	http.ServeContent(w, r, "transcript."+format, time.Time{}, rs)
+}
@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"github.com/go-chi/chi"
+	"github.com/htim/youpod"
+	"github.com/htim/youpod/core"
+	log "github.com/sirupsen/logrus"
+	"net/http"
+)
+
+//authorizedUser finds user from {username} url param and checks {token} against the user secret token.
+//Unknown users and wrong tokens are indistinguishable for clients, error response is written if false is returned
+func (h *Handler) authorizedUser(w http.ResponseWriter, r *http.Request) (core.User, bool) {
+	username := chi.URLParam(r, "username")
+
+	user, err := h.userService.FindUserByUsername(r.Context(), username)
+	if err != nil {
+
+		if err == youpod.ErrUserNotFound {
+			http.Error(w, "not found", http.StatusNotFound)
+			return core.User{}, false
+		}
+
+		log.WithError(err).WithField("username", username).Error("failed to find user")
+		http.Error(w, InternalErrorMessage, http.StatusInternalServerError)
+		return core.User{}, false
+	}
+
+	if !validToken(user, chi.URLParam(r, "token")) {
+		http.Error(w, "not found", http.StatusNotFound)
+		return core.User{}, false
+	}
+
+	return user, true
+}
+
+func validToken(user core.User, token string) bool {
+	if user.Token == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(user.Token), []byte(token)) == 1
+}
+
+//EvictUser removes cached responses of user, e.g. after token rotation
+func (h *Handler) EvictUser(user core.User) {
+	h.responseCache.RemoveIf(func(key interface{}) bool {
+		fk, ok := key.(fileKey)
+		return ok && fk.username == user.Username
+	})
+}
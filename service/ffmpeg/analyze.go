@@ -0,0 +1,91 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+//Loudness is measured by the first pass of loudnorm filter, values are passed to the second pass as is
+type Loudness struct {
+	InputI       string `json:"input_i"`
+	InputTP      string `json:"input_tp"`
+	InputLRA     string `json:"input_lra"`
+	InputThresh  string `json:"input_thresh"`
+	TargetOffset string `json:"target_offset"`
+}
+
+type Silence struct {
+	Start float64
+	End   float64 //zero when silence lasts till the end
+}
+
+type Analysis struct {
+	Loudness *Loudness
+	Silences []Silence
+}
+
+var (
+	silenceStart = regexp.MustCompile(`silence_start: (-?[0-9.]+)`)
+	silenceEnd   = regexp.MustCompile(`silence_end: (-?[0-9.]+)`)
+)
+
+//Analyze decodes source once with loudnorm and silencedetect filters, the ones not needed are passed as empty strings
+func Analyze(ctx context.Context, source string, loudnorm string, silencedetect string) (Analysis, error) {
+	filters := make([]string, 0, 2)
+	if silencedetect != "" {
+		filters = append(filters, silencedetect)
+	}
+	if loudnorm != "" {
+		filters = append(filters, loudnorm+":print_format=json")
+	}
+	if len(filters) == 0 {
+		return Analysis{}, nil
+	}
+
+	var stderr bytes.Buffer
+
+	cmd := exec.CommandContext(ctx, "ffmpeg", "-hide_banner", "-nostats", "-i", source, "-vn", "-af", strings.Join(filters, ","), "-f", "null", "-")
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return Analysis{}, errors.Wrapf(err, "ffmpeg failed: %s", tail(stderr.String()))
+	}
+
+	out := stderr.String()
+
+	var a Analysis
+
+	if loudnorm != "" {
+		//loudnorm prints json summary after everything else
+		start := strings.LastIndex(out, "{")
+		end := strings.LastIndex(out, "}")
+		if start < 0 || end < start {
+			return Analysis{}, errors.New("cannot find loudnorm summary")
+		}
+		var l Loudness
+		if err := json.Unmarshal([]byte(out[start:end+1]), &l); err != nil {
+			return Analysis{}, errors.Wrap(err, "cannot unmarshal loudnorm summary")
+		}
+		a.Loudness = &l
+	}
+
+	if silencedetect != "" {
+		for _, line := range strings.Split(out, "\n") {
+			if m := silenceStart.FindStringSubmatch(line); m != nil {
+				start, _ := strconv.ParseFloat(m[1], 64)
+				a.Silences = append(a.Silences, Silence{Start: start})
+			}
+			if m := silenceEnd.FindStringSubmatch(line); m != nil && len(a.Silences) > 0 {
+				a.Silences[len(a.Silences)-1].End, _ = strconv.ParseFloat(m[1], 64)
+			}
+		}
+	}
+
+	return a, nil
+}
@@ -0,0 +1,197 @@
+// Package ffmpeg wraps ffmpeg and ffprobe command line tools used to convert media to feed audio formats
+package ffmpeg
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/htim/youpod/core"
+	"github.com/pkg/errors"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+//Check fails if ffmpeg or ffprobe is not installed
+func Check() error {
+	for _, tool := range []string{"ffmpeg", "ffprobe"} {
+		if err := exec.Command(tool, "-version").Run(); err != nil {
+			return errors.Wrapf(err, "cannot find %s", tool)
+		}
+	}
+	return nil
+}
+
+type Info struct {
+	Streams []struct {
+		CodecType   string `json:"codec_type"`
+		CodecName   string `json:"codec_name"`
+		Disposition struct {
+			AttachedPic int `json:"attached_pic"`
+		} `json:"disposition"`
+	} `json:"streams"`
+	Format struct {
+		FormatName string            `json:"format_name"`
+		Duration   string            `json:"duration"`
+		Tags       map[string]string `json:"tags"`
+	} `json:"format"`
+}
+
+func Probe(ctx context.Context, path string) (Info, error) {
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.CommandContext(ctx, "ffprobe", "-v", "error", "-print_format", "json", "-show_format", "-show_streams", path)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return Info{}, errors.Wrapf(err, "ffprobe failed: %s", tail(stderr.String()))
+	}
+
+	var i Info
+	if err := json.Unmarshal(stdout.Bytes(), &i); err != nil {
+		return Info{}, errors.Wrap(err, "cannot unmarshal ffprobe output")
+	}
+
+	return i, nil
+}
+
+//Convert extracts audio from source in format f, metadata of source is dropped
+func Convert(ctx context.Context, source, target string, f core.AudioFormat) error {
+	return Filter(ctx, source, target, "", f)
+}
+
+//Filter is Convert with audio filter graph applied, empty filter keeps audio as is
+func Filter(ctx context.Context, source, target, filter string, f core.AudioFormat) error {
+	var stderr bytes.Buffer
+
+	args := []string{"-y", "-i", source, "-vn", "-map_metadata", "-1"}
+	if filter != "" {
+		args = append(args, "-af", filter)
+	}
+	args = append(args, codecArgs(f)...)
+	args = append(args, target)
+
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return errors.Wrapf(err, "ffmpeg failed: %s", tail(stderr.String()))
+	}
+	return nil
+}
+
+func codecArgs(f core.AudioFormat) []string {
+	var args []string
+	switch f.Codec {
+	case core.M4a:
+		//moov atom goes first, so podcast apps can start playing before the whole file is downloaded
+		args = []string{"-codec:a", "aac", "-movflags", "+faststart", "-f", "ipod"}
+		if f.Bitrate == 0 {
+			args = append(args, "-b:a", "128k")
+		}
+	case core.Opus:
+		args = []string{"-codec:a", "libopus", "-f", "ogg"}
+		if f.Bitrate == 0 {
+			args = append(args, "-b:a", "64k")
+		}
+	default:
+		args = []string{"-codec:a", "libmp3lame"}
+		if f.Bitrate == 0 {
+			args = append(args, "-q:a", "2")
+		}
+	}
+	if f.Bitrate != 0 {
+		args = append(args, "-b:a", strconv.Itoa(f.Bitrate)+"k")
+	}
+	return args
+}
+
+func (i Info) HasAudio() bool {
+	for _, s := range i.Streams {
+		if s.CodecType == "audio" {
+			return true
+		}
+	}
+	return false
+}
+
+//Matches is true when file is already in format f and can be stored as is.
+//Files are converted whenever bitrate is chosen explicitly. Cover art is the only other stream kept
+func (i Info) Matches(f core.AudioFormat) bool {
+	if f.Bitrate != 0 {
+		return false
+	}
+
+	var format, codec string
+	switch f.Codec {
+	case core.M4a:
+		format, codec = "mov,mp4,m4a,3gp,3g2,mj2", "aac"
+	case core.Opus:
+		format, codec = "ogg", "opus"
+	default:
+		format, codec = "mp3", "mp3"
+	}
+
+	if i.Format.FormatName != format {
+		return false
+	}
+	for _, s := range i.Streams {
+		if s.CodecType == "audio" && s.CodecName != codec {
+			return false
+		}
+		if s.CodecType != "audio" && s.Disposition.AttachedPic == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+//Duration in seconds, zero if unknown
+func (i Info) Duration() int64 {
+	d, err := strconv.ParseFloat(i.Format.Duration, 64)
+	if err != nil {
+		return 0
+	}
+	return int64(d)
+}
+
+//Tag looks up tag case insensitively, as containers differ in tag case
+func (i Info) Tag(name string) string {
+	for k, v := range i.Format.Tags {
+		if strings.EqualFold(k, name) {
+			return strings.TrimSpace(v)
+		}
+	}
+	return ""
+}
+
+//Input returns path of the file backing content, contents not backed by files are copied to tmp
+func Input(content io.Reader, tmp string) (string, error) {
+	if file, ok := content.(*os.File); ok {
+		return file.Name(), nil
+	}
+
+	out, err := os.Create(tmp)
+	if err != nil {
+		return "", errors.Wrapf(err, "cannot create file: %s", tmp)
+	}
+
+	if _, err := io.Copy(out, content); err != nil {
+		_ = out.Close()
+		return "", errors.Wrap(err, "cannot copy file content")
+	}
+
+	return tmp, out.Close()
+}
+
+//tail keeps the end of tool output, where the actual error is
+func tail(s string) string {
+	const max = 500
+	if len(s) > max {
+		return s[len(s)-max:]
+	}
+	return s
+}
@@ -0,0 +1,120 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/htim/youpod/core"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+//Tags are written into files by Remux: ID3v2 frames for mp3, MP4 atoms for m4a and Vorbis comments for opus
+type Tags struct {
+	Title   string
+	Artist  string
+	Album   string
+	Date    time.Time
+	Comment string
+	//Cover is a path to jpeg image, it is not written into opus files as ogg muxer does not support pictures
+	Cover string
+
+	Chapters []core.Chapter
+	Duration float64 //seconds, end of the last chapter if its end is unknown
+}
+
+//Remux copies audio from source to target with tags, audio is not re-encoded
+func Remux(ctx context.Context, source, target string, t Tags) error {
+	meta := target + ".ffmetadata"
+	if err := ioutil.WriteFile(meta, []byte(t.ffmetadata()), 0600); err != nil {
+		return errors.Wrap(err, "cannot write metadata file")
+	}
+	defer func() {
+		_ = os.Remove(meta)
+	}()
+
+	cover := t.Cover != "" && !strings.HasSuffix(target, "."+core.Opus)
+
+	args := []string{"-y", "-i", source, "-f", "ffmetadata", "-i", meta}
+	if cover {
+		args = append(args, "-i", t.Cover)
+	}
+	args = append(args, "-map", "0:a", "-map_metadata", "1", "-map_chapters", "1")
+	if cover {
+		args = append(args, "-map", "2:v", "-disposition:v", "attached_pic",
+			"-metadata:s:v", "title=Cover", "-metadata:s:v", "comment=Cover (front)")
+	}
+	args = append(args, "-codec", "copy")
+
+	switch {
+	case strings.HasSuffix(target, "."+core.Mp3):
+		//ID3v2.3 is the version podcast apps and players read reliably, cover goes to APIC frame
+		args = append(args, "-id3v2_version", "3")
+	case strings.HasSuffix(target, "."+core.M4a):
+		args = append(args, "-movflags", "+faststart")
+	}
+	args = append(args, target)
+
+	var stderr bytes.Buffer
+
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return errors.Wrapf(err, "ffmpeg failed: %s", tail(stderr.String()))
+	}
+	return nil
+}
+
+//ffmetadata renders tags in ffmpeg metadata file format, chapter times are in milliseconds
+func (t Tags) ffmetadata() string {
+	var buf bytes.Buffer
+	buf.WriteString(";FFMETADATA1\n")
+
+	global := [][2]string{
+		{"title", t.Title},
+		{"artist", t.Artist},
+		{"album_artist", t.Artist},
+		{"album", t.Album},
+		{"genre", "Podcast"},
+		{"comment", t.Comment},
+	}
+	if !t.Date.IsZero() {
+		global = append(global, [2]string{"date", t.Date.Format("2006-01-02")})
+	}
+	for _, kv := range global {
+		if kv[1] != "" {
+			buf.WriteString(fmt.Sprintf("%s=%s\n", kv[0], escape(kv[1])))
+		}
+	}
+
+	for i, c := range t.Chapters {
+		end := c.End
+		if end == 0 {
+			if i+1 < len(t.Chapters) {
+				end = t.Chapters[i+1].Start
+			} else {
+				end = t.Duration
+			}
+		}
+		if end <= c.Start {
+			continue
+		}
+
+		buf.WriteString("[CHAPTER]\nTIMEBASE=1/1000\n")
+		buf.WriteString(fmt.Sprintf("START=%d\nEND=%d\n", int64(c.Start*1000), int64(end*1000)))
+		buf.WriteString(fmt.Sprintf("title=%s\n", escape(c.Title)))
+	}
+
+	return buf.String()
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, `=`, `\=`, `;`, `\;`, `#`, `\#`, "\n", "\\\n")
+
+func escape(s string) string {
+	return escaper.Replace(s)
+}
@@ -0,0 +1,50 @@
+package ffmpeg
+
+import (
+	"github.com/htim/youpod/core"
+	"testing"
+	"time"
+)
+
+func TestFFMetadata(t *testing.T) {
+	tags := Tags{
+		Title:  "Episode #1",
+		Artist: "Channel",
+		Album:  "YouPod feed",
+		Date:   time.Date(2019, 7, 1, 0, 0, 0, 0, time.UTC),
+		Chapters: []core.Chapter{
+			{Title: "Intro", Start: 0},
+			{Title: "Q&A; part=1", Start: 61.5, End: 90},
+			{Title: "Outro", Start: 90},
+		},
+		Duration: 100,
+	}
+
+	expected := `;FFMETADATA1
+title=Episode \#1
+artist=Channel
+album_artist=Channel
+album=YouPod feed
+genre=Podcast
+date=2019-07-01
+[CHAPTER]
+TIMEBASE=1/1000
+START=0
+END=61500
+title=Intro
+[CHAPTER]
+TIMEBASE=1/1000
+START=61500
+END=90000
+title=Q&A\; part\=1
+[CHAPTER]
+TIMEBASE=1/1000
+START=90000
+END=100000
+title=Outro
+`
+
+	if actual := tags.ffmetadata(); actual != expected {
+		t.Errorf("unexpected metadata:\n%s", actual)
+	}
+}
@@ -3,6 +3,8 @@ package gdrive
 import (
 	"context"
 	"fmt"
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/htim/youpod"
 	"github.com/htim/youpod/auth"
 	"github.com/htim/youpod/core"
 	"github.com/pkg/errors"
@@ -12,18 +14,50 @@ import (
 	"google.golang.org/api/googleapi"
 	"google.golang.org/api/option"
 	"io"
-	"io/ioutil"
 )
 
+const (
+	DefaultBlockSize = 4 << 20
+
+	//total size of cached blocks shared between all readers
+	blockCacheSize = 64 << 20
+	sizeCacheSize  = 256
+)
+
+type sizeKey struct {
+	username string
+	fileID   string
+}
+
 type Client struct {
 	userRepository core.UserRepository
 	config         oauth2.Config
+
+	blockSize int64
+	blocks    *blockCache
+	sizes     *lru.Cache
 }
 
+//NewClient creates google drive client. Files are streamed by blocks of blockSize bytes
 func NewClient(
 	userRepository core.UserRepository,
 	clientID, clientSecret, redirectUrl string,
-) *Client {
+	blockSize int64,
+) (*Client, error) {
+	if blockSize <= 0 {
+		blockSize = DefaultBlockSize
+	}
+
+	blocks, err := newBlockCache(int(blockCacheSize/blockSize) + 1)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot init block cache")
+	}
+
+	sizes, err := lru.New(sizeCacheSize)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot init file size cache")
+	}
+
 	return &Client{
 		userRepository: userRepository,
 		config: oauth2.Config{
@@ -36,10 +70,13 @@ func NewClient(
 				"https://www.googleapis.com/auth/drive.metadata",
 			},
 		},
-	}
+		blockSize: blockSize,
+		blocks:    blocks,
+		sizes:     sizes,
+	}, nil
 }
 
-func (c *Client) Save(user core.User, file core.File) (err error) {
+func (c *Client) Save(ctx context.Context, user core.User, file core.File) (err error) {
 
 	filesService, err := c.filesService(user)
 	if err != nil {
@@ -55,7 +92,7 @@ func (c *Client) Save(user core.User, file core.File) (err error) {
 		Name: file.Name,
 	}
 
-	_, err = filesService.Create(driveFile).Media(file.Content).Do()
+	_, err = filesService.Create(driveFile).Media(file.Content).Context(ctx).Do()
 	if err != nil {
 		return errors.Wrap(err, "cannot upload file")
 	}
@@ -63,27 +100,56 @@ func (c *Client) Save(user core.User, file core.File) (err error) {
 	return nil
 }
 
-func (c *Client) Get(user core.User, ID string) (io.ReadSeeker, error) {
+//Get uses ctx to find file size only, blocks of content are shared between readers and fetched regardless of it.
+//Sizes and blocks are cached per user, so cached content is served only to users who fetched it from their drive
+func (c *Client) Get(ctx context.Context, user core.User, ID string) (io.ReadSeeker, error) {
 	filesService, err := c.filesService(user)
 	if err != nil {
 		return nil, errors.Wrap(err, "cannot init google drive api client")
 	}
 
-	file, err := filesService.Get(ID).Fields("size").Do()
-	if err != nil {
-		return nil, errors.Wrap(err, "cannot load file from google drive")
+	key := sizeKey{username: user.Username, fileID: ID}
+
+	size, ok := c.sizes.Get(key)
+	if !ok {
+		file, err := filesService.Get(ID).Fields("size").Context(ctx).Do()
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot load file from google drive")
+		}
+		size = file.Size
+		c.sizes.Add(key, size)
 	}
 
 	return &readSeeker{
-		user:     user,
-		client:   c,
-		fileID:   ID,
-		fileSize: file.Size,
-		offset:   0,
+		client:       c,
+		filesService: filesService,
+		username:     user.Username,
+		fileID:       ID,
+		fileSize:     size.(int64),
+		offset:       0,
 	}, nil
 
 }
 
+func (c *Client) Delete(ctx context.Context, user core.User, ID string) error {
+	filesService, err := c.filesService(user)
+	if err != nil {
+		return errors.Wrap(err, "cannot init google drive api client")
+	}
+
+	if err = filesService.Delete(ID).Context(ctx).Do(); err != nil {
+		if err2, ok := err.(*googleapi.Error); ok && err2.Code == 404 {
+			return youpod.ErrFileNotFound
+		}
+		return errors.Wrap(err, "cannot delete file from google drive")
+	}
+
+	c.sizes.Remove(sizeKey{username: user.Username, fileID: ID})
+	c.blocks.remove(user.Username, ID)
+
+	return nil
+}
+
 func (c *Client) FolderExists(user core.User, folderID string) (bool, error) {
 	filesService, err := c.filesService(user)
 
@@ -139,12 +205,12 @@ func (c *Client) CreateRootFolder(user core.User, folderName, folderID string) e
 	return nil
 }
 
-func (c *Client) GenerateID(user core.User) (string, error) {
+func (c *Client) GenerateID(ctx context.Context, user core.User) (string, error) {
 	filesService, err := c.filesService(user)
 	if err != nil {
 		return "", errors.Wrap(err, "cannot init google drive api")
 	}
-	generatedID, err := filesService.GenerateIds().Count(1).Do()
+	generatedID, err := filesService.GenerateIds().Count(1).Context(ctx).Do()
 	if err != nil {
 		return "", errors.Wrap(err, "cannot generate google drive FileID")
 	}
@@ -159,12 +225,12 @@ func (c *Client) filesService(user core.User) (*drive.FilesService, error) {
 			return nil, errors.Wrapf(err, "cannot refresh google drive token for user: %s", user.Username)
 		}
 		user.GDriveToken = newToken
-		if err = c.userRepository.SaveUser(context.Background(), user); err != nil {
+		if err = c.userRepository.UpdateGDriveToken(context.Background(), user); err != nil {
 			return nil, errors.Wrapf(err, "cannot update google drive token for user: %s", user.Username)
 		}
 	}
 
-	ts, err := tokenSource(user.GDriveToken)
+	ts, err := c.tokenSource(user.GDriveToken)
 	if err != nil {
 		return nil, errors.Wrap(err, "cannot convert token source")
 	}
@@ -177,63 +243,12 @@ func (c *Client) filesService(user core.User) (*drive.FilesService, error) {
 	return drive.NewFilesService(service), nil
 }
 
-func tokenSource(token auth.OAuth2Token) (oauth2.TokenSource, error) {
+//tokenSource refreshes token when it expires, so long living clients (e.g. streaming readers) stay authenticated
+func (c *Client) tokenSource(token auth.OAuth2Token) (oauth2.TokenSource, error) {
 	tok := oauth2.Token{
 		AccessToken:  token.AccessToken,
 		RefreshToken: token.RefreshToken,
+		Expiry:       token.Expiry,
 	}
-	return oauth2.StaticTokenSource(&tok), nil
-}
-
-type readSeeker struct {
-	user     core.User
-	client   *Client
-	fileID   string
-	fileSize int64
-	offset   int64
-}
-
-func (s *readSeeker) Read(p []byte) (int, error) {
-	if s.offset >= s.fileSize {
-		return 0, io.EOF
-	}
-
-	filesService, err := s.client.filesService(s.user)
-	if err != nil {
-		return 0, err
-	}
-
-	bytesHeader := fmt.Sprintf("bytes=%d-%d", s.offset, s.offset+int64(len(p)-1))
-	getCall := filesService.Get(s.fileID)
-	getCall.Header().Set("Range", bytesHeader)
-	response, err := getCall.Download()
-	if err != nil {
-		return 0, err
-	}
-	buf, err := ioutil.ReadAll(response.Body)
-	if err != nil {
-		return 0, err
-	}
-	n := copy(p, buf)
-	s.offset += int64(n)
-	return len(p), nil
-}
-
-func (s *readSeeker) Seek(offset int64, whence int) (int64, error) {
-	var abs int64
-	switch whence {
-	case io.SeekStart:
-		abs = offset
-	case io.SeekCurrent:
-		abs = s.offset + offset
-	case io.SeekEnd:
-		abs = s.fileSize + offset
-	default:
-		return 0, errors.New("gdrive.Reader.Seek: invalid whence")
-	}
-	if abs < 0 {
-		return 0, errors.New("gdrive.Reader.Seek: negative position")
-	}
-	s.offset = abs
-	return abs, nil
+	return c.config.TokenSource(context.Background(), &tok), nil
 }
@@ -0,0 +1,161 @@
+package gdrive
+
+import (
+	"fmt"
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/api/drive/v3"
+	"io"
+	"io/ioutil"
+	"sync"
+)
+
+//readSeeker downloads file by aligned blocks, so small sequential reads are served from memory
+type readSeeker struct {
+	client       *Client
+	filesService *drive.FilesService
+	username     string
+	fileID       string
+	fileSize     int64
+	offset       int64
+}
+
+func (s *readSeeker) Read(p []byte) (int, error) {
+	if s.offset >= s.fileSize {
+		return 0, io.EOF
+	}
+
+	index := s.offset / s.client.blockSize
+
+	block, err := s.client.blocks.get(blockKey{username: s.username, fileID: s.fileID, index: index}, s.fetch)
+	if err != nil {
+		return 0, err
+	}
+
+	start := s.offset - index*s.client.blockSize
+	if start >= int64(len(block)) {
+		return 0, io.ErrUnexpectedEOF
+	}
+
+	n := copy(p, block[start:])
+	s.offset += int64(n)
+	return n, nil
+}
+
+func (s *readSeeker) Seek(offset int64, whence int) (int64, error) {
+	var abs int64
+	switch whence {
+	case io.SeekStart:
+		abs = offset
+	case io.SeekCurrent:
+		abs = s.offset + offset
+	case io.SeekEnd:
+		abs = s.fileSize + offset
+	default:
+		return 0, errors.New("gdrive.Reader.Seek: invalid whence")
+	}
+	if abs < 0 {
+		return 0, errors.New("gdrive.Reader.Seek: negative position")
+	}
+	s.offset = abs
+	return abs, nil
+}
+
+func (s *readSeeker) fetch(key blockKey) ([]byte, error) {
+	start := key.index * s.client.blockSize
+	end := start + s.client.blockSize - 1
+	if end >= s.fileSize {
+		end = s.fileSize - 1
+	}
+
+	log.Debugf("downloading block %d of %s (bytes %d-%d)", key.index, key.fileID, start, end)
+
+	getCall := s.filesService.Get(s.fileID)
+	getCall.Header().Set("Range", fmt.Sprintf("bytes=%d-%d", start, end))
+	response, err := getCall.Download()
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot download block %d of file %s", key.index, key.fileID)
+	}
+	defer func() {
+		if err := response.Body.Close(); err != nil {
+			log.WithError(err).Error("cannot close google drive download response body")
+		}
+	}()
+
+	buf, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot read block %d of file %s", key.index, key.fileID)
+	}
+	return buf, nil
+}
+
+//blockKey includes owner of the file, a block fetched with one user's drive access is not served to others
+type blockKey struct {
+	username string
+	fileID   string
+	index    int64
+}
+
+//blockCache keeps recently downloaded blocks. Concurrent requests for the same block share a single download
+type blockCache struct {
+	lru *lru.Cache
+
+	mu       sync.Mutex
+	inflight map[blockKey]*blockCall
+}
+
+type blockCall struct {
+	wg   sync.WaitGroup
+	data []byte
+	err  error
+}
+
+func newBlockCache(size int) (*blockCache, error) {
+	c, err := lru.New(size)
+	if err != nil {
+		return nil, err
+	}
+	return &blockCache{
+		lru:      c,
+		inflight: make(map[blockKey]*blockCall),
+	}, nil
+}
+
+func (c *blockCache) get(key blockKey, fetch func(key blockKey) ([]byte, error)) ([]byte, error) {
+	if v, ok := c.lru.Get(key); ok {
+		return v.([]byte), nil
+	}
+
+	c.mu.Lock()
+	if call, ok := c.inflight[key]; ok {
+		c.mu.Unlock()
+		call.wg.Wait()
+		return call.data, call.err
+	}
+	call := &blockCall{}
+	call.wg.Add(1)
+	c.inflight[key] = call
+	c.mu.Unlock()
+
+	call.data, call.err = fetch(key)
+	if call.err == nil {
+		c.lru.Add(key, call.data)
+	}
+
+	c.mu.Lock()
+	delete(c.inflight, key)
+	c.mu.Unlock()
+	call.wg.Done()
+
+	return call.data, call.err
+}
+
+//remove evicts cached blocks of the file
+func (c *blockCache) remove(username, fileID string) {
+	for _, k := range c.lru.Keys() {
+		if key := k.(blockKey); key.username == username && key.fileID == fileID {
+			c.lru.Remove(key)
+		}
+	}
+}
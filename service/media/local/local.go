@@ -0,0 +1,109 @@
+// Package local implements media.Store on top of local filesystem, one directory per user
+package local
+
+import (
+	"context"
+	"github.com/htim/youpod"
+	"github.com/htim/youpod/core"
+	"github.com/pkg/errors"
+	"github.com/rs/xid"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type Store struct {
+	root string
+}
+
+func NewStore(root string) (*Store, error) {
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, errors.Wrapf(err, "cannot create root directory: %s", root)
+	}
+	return &Store{root: root}, nil
+}
+
+func (s *Store) GenerateID(ctx context.Context, user core.User) (string, error) {
+	return xid.New().String(), nil
+}
+
+func (s *Store) Save(ctx context.Context, user core.User, file core.File) error {
+	if file.FileID == "" {
+		return errors.New("file id must be specified")
+	}
+
+	path, err := s.path(user, file.FileID)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return errors.Wrapf(err, "cannot create user directory for user: %s", user.Username)
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return errors.Wrapf(err, "cannot create file: %s", path)
+	}
+
+	if _, err = io.Copy(f, file.Content); err != nil {
+		if err := f.Close(); err != nil {
+			log.WithError(err).Errorf("cannot close file: %s", path)
+		}
+		if err := os.Remove(path); err != nil {
+			log.WithError(err).Errorf("cannot remove partially written file: %s", path)
+		}
+		return errors.Wrapf(err, "cannot write file: %s", path)
+	}
+
+	if err = f.Close(); err != nil {
+		return errors.Wrapf(err, "cannot close file: %s", path)
+	}
+
+	return nil
+}
+
+func (s *Store) Get(ctx context.Context, user core.User, ID string) (io.ReadSeeker, error) {
+	path, err := s.path(user, ID)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, youpod.ErrFileNotFound
+		}
+		return nil, errors.Wrapf(err, "cannot open file: %s", path)
+	}
+
+	return f, nil
+}
+
+func (s *Store) Delete(ctx context.Context, user core.User, ID string) error {
+	path, err := s.path(user, ID)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil {
+		if os.IsNotExist(err) {
+			return youpod.ErrFileNotFound
+		}
+		return errors.Wrapf(err, "cannot remove file: %s", path)
+	}
+
+	return nil
+}
+
+//path returns file location, user supplied parts are not allowed to escape root directory
+func (s *Store) path(user core.User, ID string) (string, error) {
+	for _, part := range []string{user.Username, ID} {
+		if part == "" || part == "." || part == ".." || strings.ContainsAny(part, `/\`) {
+			return "", errors.Errorf("invalid path element: '%s'", part)
+		}
+	}
+	return filepath.Join(s.root, user.Username, ID), nil
+}
@@ -0,0 +1,63 @@
+package local
+
+import (
+	"context"
+	"github.com/htim/youpod"
+	"github.com/htim/youpod/core"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestSaveAndGet(t *testing.T) {
+	root, err := ioutil.TempDir("", "youpod_local_store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	s, err := NewStore(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	user := core.User{Username: "test_user"}
+
+	id, err := s.GenerateID(ctx, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := core.File{
+		Metadata: core.Metadata{FileID: id},
+		Content:  ioutil.NopCloser(strings.NewReader("content")),
+	}
+
+	if err = s.Save(ctx, user, f); err != nil {
+		t.Fatal(err)
+	}
+
+	rs, err := s.Get(ctx, user, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.(*os.File).Close()
+
+	bb, err := ioutil.ReadAll(rs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bb) != "content" {
+		t.Errorf("unexpected content: %s", string(bb))
+	}
+
+	if _, err = s.Get(ctx, user, "unknown"); err != youpod.ErrFileNotFound {
+		t.Errorf("expected ErrFileNotFound, got %v", err)
+	}
+
+	if _, err = s.Get(ctx, user, ".."); err == nil {
+		t.Error("expected error for path outside of root")
+	}
+}
@@ -1,54 +1,130 @@
-package media
-
 // Package media handles storing and retrieval of media files (typically audios extracted from YouTube videos)
 // Implements core.MediaService
+package media
 
 import (
+	"bytes"
 	"context"
+	"github.com/htim/youpod"
 	"github.com/htim/youpod/core"
+	"github.com/htim/youpod/service/transcript"
 	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
 	"io"
+	"io/ioutil"
 )
 
 type Store interface {
-	GenerateID(user core.User) (ID string, err error)
-	Save(user core.User, file core.File) (err error)
-	Get(user core.User, ID string) (rs io.ReadSeeker, err error)
+	GenerateID(ctx context.Context, user core.User) (ID string, err error)
+	Save(ctx context.Context, user core.User, file core.File) (err error)
+	//Get returns content which may be read lazily within ctx
+	Get(ctx context.Context, user core.User, ID string) (rs io.ReadSeeker, err error)
+	Delete(ctx context.Context, user core.User, ID string) (err error)
+}
+
+//URLSigner is implemented by stores which are able to serve files to clients directly
+type URLSigner interface {
+	//SignedURL returns temporary download url or empty string if direct download is not available
+	SignedURL(user core.User, ID string) (url string, err error)
 }
 
 type Service struct {
 	metadataService core.MetadataRepository
 	store           Store
+
+	deleteListeners []func(user core.User, fileID string)
 }
 
 func NewService(metadataService core.MetadataRepository, store Store) *Service {
 	return &Service{metadataService: metadataService, store: store}
 }
 
-func (s *Service) SaveFile(u core.User, f core.File) (string, error) {
+//SaveFile fails with youpod.ErrFileExists if user already has a file with the same source ID,
+//stored content is removed in that case
+func (s *Service) SaveFile(ctx context.Context, u core.User, f core.File) (string, error) {
+
+	f.Username = u.Username
 
 	var err error
 	if f.FileID == "" {
-		f.FileID, err = s.store.GenerateID(u)
+		f.FileID, err = s.store.GenerateID(ctx, u)
 		if err != nil {
 			return "", err
 		}
 	}
 
-	if err := s.store.Save(u, f); err != nil {
+	if err := s.store.Save(ctx, u, f); err != nil {
 		return "", err
 	}
 
-	if err := s.metadataService.SaveFileMetadata(context.Background(), f.Metadata); err != nil {
+	//transcript is optional, file is saved without it if it cannot be stored
+	if len(f.Transcript) > 0 {
+		if f.Transcripts, err = s.saveTranscripts(ctx, u, f); err != nil {
+			log.WithError(err).WithField("user", u.Username).Error("cannot save transcript")
+		}
+	}
+
+	if err := s.metadataService.SaveFileMetadata(ctx, f.Metadata); err != nil {
+		if err == youpod.ErrFileExists {
+			s.discard(u, f.Metadata)
+		}
 		return "", errors.Wrapf(err, "cannot save file metadata (user ID '%s', file ID '%s')", u.Username, f.FileID)
 	}
 
 	return f.FileID, nil
 }
 
-func (s *Service) GetFileContent(user core.User, fileID string) (io.ReadSeeker, error) {
+//discard removes content of a file whose metadata has not been saved
+func (s *Service) discard(u core.User, m core.Metadata) {
+	ctx := context.Background()
+	IDs := []string{m.FileID}
+	for _, ID := range m.Transcripts {
+		IDs = append(IDs, ID)
+	}
+	for _, ID := range IDs {
+		if err := s.store.Delete(ctx, u, ID); err != nil {
+			log.WithError(err).WithField("user", u.Username).Errorf("cannot remove discarded file '%s'", ID)
+		}
+	}
+}
+
+//saveTranscripts stores transcript in every format, IDs of stored transcripts are returned by format
+func (s *Service) saveTranscripts(ctx context.Context, u core.User, f core.File) (map[string]string, error) {
+	IDs := make(map[string]string)
+
+	for _, format := range core.TranscriptFormats {
+		bb, err := transcript.Render(f.Transcript, format)
+		if err != nil {
+			return IDs, err
+		}
+
+		ID, err := s.store.GenerateID(ctx, u)
+		if err != nil {
+			return IDs, err
+		}
+
+		t := core.File{
+			Metadata: core.Metadata{
+				FileID:      ID,
+				Name:        f.Name + "." + format,
+				ContentType: core.TranscriptType(format),
+				Size:        int64(len(bb)),
+			},
+			Content: ioutil.NopCloser(bytes.NewReader(bb)),
+		}
+
+		if err := s.store.Save(ctx, u, t); err != nil {
+			return IDs, errors.Wrapf(err, "cannot save %s transcript", format)
+		}
+		IDs[format] = ID
+	}
+
+	return IDs, nil
+}
+
+func (s *Service) GetFileContent(ctx context.Context, user core.User, fileID string) (io.ReadSeeker, error) {
 
-	rs, err := s.store.Get(user, fileID)
+	rs, err := s.store.Get(ctx, user, fileID)
 	if err != nil {
 		return nil, errors.Wrapf(err, "cannot get file from store (user ID '%s', fileID '%s')", user.Username, fileID)
 	}
@@ -56,10 +132,58 @@ func (s *Service) GetFileContent(user core.User, fileID string) (io.ReadSeeker,
 	return rs, nil
 }
 
-func (s *Service) GetFileMetadata(user core.User, fileID string, ctx context.Context) (core.Metadata, error) {
-	metadata, err := s.metadataService.GetFileMetadata(nil, fileID)
+func (s *Service) GetFileURL(ctx context.Context, user core.User, fileID string) (string, error) {
+	signer, ok := s.store.(URLSigner)
+	if !ok {
+		return "", nil
+	}
+
+	url, err := signer.SignedURL(user, fileID)
+	if err != nil {
+		return "", errors.Wrapf(err, "cannot sign file url (user ID '%s', fileID '%s')", user.Username, fileID)
+	}
+
+	return url, nil
+}
+
+func (s *Service) GetFileMetadata(ctx context.Context, user core.User, fileID string) (core.Metadata, error) {
+	metadata, err := s.metadataService.GetFileMetadata(ctx, fileID)
 	if err != nil {
 		return core.Metadata{}, errors.Wrapf(err, "cannot load file metadata (user ID '%s', file ID '%s')", user.Username, fileID)
 	}
 	return metadata, nil
 }
+
+//DeleteFile removes file content from the store and its metadata. Missing content is not an error,
+//so partially deleted files can be deleted again
+func (s *Service) DeleteFile(ctx context.Context, user core.User, fileID string) error {
+	metadata, err := s.metadataService.GetFileMetadata(ctx, fileID)
+	if err != nil && err != youpod.ErrMetadataNotFound {
+		return errors.Wrapf(err, "cannot load file metadata (user ID '%s', fileID '%s')", user.Username, fileID)
+	}
+
+	for format, ID := range metadata.Transcripts {
+		if err := s.store.Delete(ctx, user, ID); err != nil && errors.Cause(err) != youpod.ErrFileNotFound {
+			return errors.Wrapf(err, "cannot delete %s transcript from store (user ID '%s', fileID '%s')", format, user.Username, fileID)
+		}
+	}
+
+	if err := s.store.Delete(ctx, user, fileID); err != nil && errors.Cause(err) != youpod.ErrFileNotFound {
+		return errors.Wrapf(err, "cannot delete file from store (user ID '%s', fileID '%s')", user.Username, fileID)
+	}
+
+	if err := s.metadataService.DeleteFileMetadata(ctx, fileID); err != nil && err != youpod.ErrMetadataNotFound {
+		return errors.Wrapf(err, "cannot delete file metadata (user ID '%s', fileID '%s')", user.Username, fileID)
+	}
+
+	for _, l := range s.deleteListeners {
+		l(user, fileID)
+	}
+
+	return nil
+}
+
+//OnDelete registers listener which is called after file is deleted, e.g. to evict cached responses
+func (s *Service) OnDelete(listener func(user core.User, fileID string)) {
+	s.deleteListeners = append(s.deleteListeners, listener)
+}
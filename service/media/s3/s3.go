@@ -0,0 +1,432 @@
+// Package s3 implements media.Store on top of S3 compatible object storage (AWS S3, MinIO, etc).
+// Objects are stored with '<username>/<file ID>' keys
+package s3
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"github.com/htim/youpod"
+	"github.com/htim/youpod/core"
+	"github.com/pkg/errors"
+	"github.com/rs/xid"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	minPartSize     = 5 << 20
+	defaultPartSize = 8 << 20
+)
+
+type Config struct {
+	Endpoint  string //e.g. https://s3.amazonaws.com or http://localhost:9000
+	Bucket    string
+	Region    string
+	AccessKey string
+	SecretKey string
+
+	//PathStyle addresses bucket as endpoint/bucket instead of bucket.endpoint, required by MinIO
+	PathStyle bool
+	PartSize  int64
+
+	//PresignTTL enables redirecting clients to pre-signed urls valid for given duration
+	PresignTTL time.Duration
+}
+
+type Store struct {
+	endpoint *url.URL
+	config   Config
+	signer   signer
+	client   *http.Client
+}
+
+func NewStore(config Config) (*Store, error) {
+	endpoint, err := url.Parse(config.Endpoint)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot parse endpoint: %s", config.Endpoint)
+	}
+
+	if config.Bucket == "" {
+		return nil, errors.New("bucket must be specified")
+	}
+
+	if config.Region == "" {
+		config.Region = "us-east-1"
+	}
+
+	if config.PartSize == 0 {
+		config.PartSize = defaultPartSize
+	}
+
+	if config.PartSize < minPartSize {
+		return nil, errors.Errorf("part size must be at least %d bytes", minPartSize)
+	}
+
+	return &Store{
+		endpoint: endpoint,
+		config:   config,
+		signer: signer{
+			accessKey: config.AccessKey,
+			secretKey: config.SecretKey,
+			region:    config.Region,
+		},
+		client: &http.Client{},
+	}, nil
+}
+
+func (s *Store) GenerateID(ctx context.Context, user core.User) (string, error) {
+	return xid.New().String(), nil
+}
+
+//Save uploads file content part by part, so the whole file is never kept in memory
+func (s *Store) Save(ctx context.Context, user core.User, file core.File) error {
+	if file.FileID == "" {
+		return errors.New("file id must be specified")
+	}
+
+	key := objectKey(user, file.FileID)
+
+	uploadID, err := s.createMultipartUpload(ctx, key, core.ContentType(file.Metadata))
+	if err != nil {
+		return errors.Wrapf(err, "cannot start multipart upload: %s", key)
+	}
+
+	parts, err := s.uploadParts(ctx, key, uploadID, file.Content)
+	if err == nil {
+		err = s.completeMultipartUpload(ctx, key, uploadID, parts)
+	}
+
+	if err != nil {
+		if abortErr := s.abortMultipartUpload(key, uploadID); abortErr != nil {
+			log.WithError(abortErr).WithField("key", key).Error("cannot abort multipart upload")
+		}
+		return errors.Wrapf(err, "cannot upload file: %s", key)
+	}
+
+	return nil
+}
+
+//Get keeps ctx to request object content when it is read
+func (s *Store) Get(ctx context.Context, user core.User, ID string) (io.ReadSeeker, error) {
+	key := objectKey(user, ID)
+
+	r, err := s.request(ctx, http.MethodHead, key, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.do(r, emptyPayloadHash)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot get object: %s", key)
+	}
+	closeBody(resp)
+
+	return &readSeeker{
+		ctx:   ctx,
+		store: s,
+		key:   key,
+		size:  resp.ContentLength,
+	}, nil
+}
+
+func (s *Store) Delete(ctx context.Context, user core.User, ID string) error {
+	key := objectKey(user, ID)
+
+	r, err := s.request(ctx, http.MethodDelete, key, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.do(r, emptyPayloadHash)
+	if err != nil {
+		return errors.Wrapf(err, "cannot delete object: %s", key)
+	}
+	closeBody(resp)
+
+	return nil
+}
+
+//SignedURL returns pre-signed url for downloading the file directly from the bucket.
+//Empty url is returned when pre-signing is disabled
+func (s *Store) SignedURL(user core.User, ID string) (string, error) {
+	if s.config.PresignTTL == 0 {
+		return "", nil
+	}
+	return s.signer.presign(http.MethodGet, s.objectURL(objectKey(user, ID), nil), s.config.PresignTTL, time.Now()), nil
+}
+
+type part struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+func (s *Store) createMultipartUpload(ctx context.Context, key, contentType string) (string, error) {
+	r, err := s.request(ctx, http.MethodPost, key, url.Values{"uploads": {""}}, nil)
+	if err != nil {
+		return "", err
+	}
+	r.Header.Set("Content-Type", contentType)
+
+	resp, err := s.do(r, emptyPayloadHash)
+	if err != nil {
+		return "", err
+	}
+	defer closeBody(resp)
+
+	var result struct {
+		UploadID string `xml:"UploadId"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", errors.Wrap(err, "cannot decode response")
+	}
+
+	return result.UploadID, nil
+}
+
+func (s *Store) uploadParts(ctx context.Context, key, uploadID string, content io.Reader) ([]part, error) {
+	parts := make([]part, 0)
+	buf := make([]byte, s.config.PartSize)
+
+	for number := 1; ; number++ {
+		n, err := io.ReadFull(content, buf)
+		if err == io.EOF && number > 1 {
+			break
+		}
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return nil, errors.Wrap(err, "cannot read file content")
+		}
+
+		etag, uploadErr := s.uploadPart(ctx, key, uploadID, number, buf[:n])
+		if uploadErr != nil {
+			return nil, errors.Wrapf(uploadErr, "cannot upload part %d", number)
+		}
+		parts = append(parts, part{PartNumber: number, ETag: etag})
+
+		if err != nil {
+			break
+		}
+	}
+
+	return parts, nil
+}
+
+func (s *Store) uploadPart(ctx context.Context, key, uploadID string, number int, data []byte) (string, error) {
+	query := url.Values{
+		"partNumber": {strconv.Itoa(number)},
+		"uploadId":   {uploadID},
+	}
+
+	r, err := s.request(ctx, http.MethodPut, key, query, bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	r.ContentLength = int64(len(data))
+
+	resp, err := s.do(r, hashHex(data))
+	if err != nil {
+		return "", err
+	}
+	closeBody(resp)
+
+	return resp.Header.Get("ETag"), nil
+}
+
+func (s *Store) completeMultipartUpload(ctx context.Context, key, uploadID string, parts []part) error {
+	body, err := xml.Marshal(struct {
+		XMLName xml.Name `xml:"CompleteMultipartUpload"`
+		Parts   []part   `xml:"Part"`
+	}{Parts: parts})
+	if err != nil {
+		return errors.Wrap(err, "cannot marshal parts")
+	}
+
+	r, err := s.request(ctx, http.MethodPost, key, url.Values{"uploadId": {uploadID}}, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	r.ContentLength = int64(len(body))
+
+	resp, err := s.do(r, hashHex(body))
+	if err != nil {
+		return err
+	}
+	defer closeBody(resp)
+
+	//complete request may fail after 200 OK has been sent, the error is in the body then
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return errors.Wrap(err, "cannot read response")
+	}
+	if bytes.Contains(respBody, []byte("<Error>")) {
+		return parseError(resp.StatusCode, respBody)
+	}
+
+	return nil
+}
+
+//abortMultipartUpload is not bound to upload context, so cancelled uploads are aborted too
+func (s *Store) abortMultipartUpload(key, uploadID string) error {
+	r, err := s.request(context.Background(), http.MethodDelete, key, url.Values{"uploadId": {uploadID}}, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(r, emptyPayloadHash)
+	if err != nil {
+		return err
+	}
+	closeBody(resp)
+	return nil
+}
+
+func (s *Store) objectURL(key string, query url.Values) *url.URL {
+	u := *s.endpoint
+	if s.config.PathStyle {
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/" + s.config.Bucket + "/" + key
+	} else {
+		u.Host = s.config.Bucket + "." + u.Host
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/" + key
+	}
+	if query != nil {
+		u.RawQuery = canonicalQuery(query)
+	}
+	return &u
+}
+
+func (s *Store) request(ctx context.Context, method, key string, query url.Values, body io.Reader) (*http.Request, error) {
+	u := s.objectURL(key, query)
+	r, err := http.NewRequest(method, u.String(), body)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot construct request: %s %s", method, u)
+	}
+	return r.WithContext(ctx), nil
+}
+
+//do signs and sends request, non 2xx responses are converted to errors
+func (s *Store) do(r *http.Request, payloadHash string) (*http.Response, error) {
+	s.signer.sign(r, payloadHash, time.Now())
+
+	resp, err := s.client.Do(r)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot make request: %s %s", r.Method, r.URL.Path)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer closeBody(resp)
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, youpod.ErrFileNotFound
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		return nil, parseError(resp.StatusCode, body)
+	}
+
+	return resp, nil
+}
+
+func parseError(status int, body []byte) error {
+	var e struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	if err := xml.Unmarshal(body, &e); err != nil || e.Code == "" {
+		return errors.Errorf("unexpected response status: %d", status)
+	}
+	return errors.Errorf("s3 error (status %d): %s: %s", status, e.Code, e.Message)
+}
+
+func closeBody(resp *http.Response) {
+	if err := resp.Body.Close(); err != nil {
+		log.WithError(err).Error("cannot close s3 response body")
+	}
+}
+
+func objectKey(user core.User, ID string) string {
+	return user.Username + "/" + ID
+}
+
+//readSeeker streams object with a single ranged request which is reopened after seek
+type readSeeker struct {
+	ctx    context.Context
+	store  *Store
+	key    string
+	size   int64
+	offset int64
+	body   io.ReadCloser
+}
+
+func (r *readSeeker) Read(p []byte) (int, error) {
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+
+	if r.body == nil {
+		req, err := r.store.request(r.ctx, http.MethodGet, r.key, nil, nil)
+		if err != nil {
+			return 0, err
+		}
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", r.offset))
+
+		resp, err := r.store.do(req, emptyPayloadHash)
+		if err != nil {
+			return 0, errors.Wrapf(err, "cannot get object range: %s", r.key)
+		}
+		r.body = resp.Body
+	}
+
+	n, err := r.body.Read(p)
+	r.offset += int64(n)
+
+	if err == io.EOF {
+		r.closeBody()
+		//body of the range has ended before the object, the next read would request the rest again
+		if r.offset < r.size {
+			err = io.ErrUnexpectedEOF
+		}
+	}
+
+	return n, err
+}
+
+func (r *readSeeker) Seek(offset int64, whence int) (int64, error) {
+	var abs int64
+	switch whence {
+	case io.SeekStart:
+		abs = offset
+	case io.SeekCurrent:
+		abs = r.offset + offset
+	case io.SeekEnd:
+		abs = r.size + offset
+	default:
+		return 0, errors.New("s3.readSeeker.Seek: invalid whence")
+	}
+	if abs < 0 {
+		return 0, errors.New("s3.readSeeker.Seek: negative position")
+	}
+	if abs != r.offset {
+		r.closeBody()
+	}
+	r.offset = abs
+	return abs, nil
+}
+
+func (r *readSeeker) Close() error {
+	r.closeBody()
+	return nil
+}
+
+func (r *readSeeker) closeBody() {
+	if r.body == nil {
+		return
+	}
+	if err := r.body.Close(); err != nil {
+		log.WithError(err).WithField("key", r.key).Debug("cannot close object body")
+	}
+	r.body = nil
+}
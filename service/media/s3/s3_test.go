@@ -0,0 +1,34 @@
+package s3
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestShortRangeIsUnexpectedEOF(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusPartialContent)
+		_, _ = w.Write([]byte("12345"))
+	}))
+	defer server.Close()
+
+	store, err := NewStore(Config{Endpoint: server.URL, Bucket: "bucket", PathStyle: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := &readSeeker{ctx: context.Background(), store: store, key: "user/file", size: 10}
+	defer r.Close()
+
+	data, err := ioutil.ReadAll(r)
+	if err != io.ErrUnexpectedEOF {
+		t.Errorf("expected unexpected EOF, actual %v", err)
+	}
+	if string(data) != "12345" {
+		t.Errorf("unexpected data: %s", data)
+	}
+}
@@ -0,0 +1,176 @@
+package s3
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AWS Signature Version 4, see https://docs.aws.amazon.com/AmazonS3/latest/API/sig-v4-authenticating-requests.html
+
+const (
+	algorithm       = "AWS4-HMAC-SHA256"
+	service         = "s3"
+	amzDateFormat   = "20060102T150405Z"
+	shortDateFormat = "20060102"
+	unsignedPayload = "UNSIGNED-PAYLOAD"
+)
+
+var emptyPayloadHash = hashHex(nil)
+
+type signer struct {
+	accessKey string
+	secretKey string
+	region    string
+}
+
+//sign adds authorization headers to request. payloadHash is hex encoded sha256 of request body
+func (s signer) sign(r *http.Request, payloadHash string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format(amzDateFormat)
+
+	r.Header.Set("X-Amz-Date", amzDate)
+	r.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headerNames := []string{"host", "x-amz-content-sha256", "x-amz-date"}
+	for name := range r.Header {
+		lower := strings.ToLower(name)
+		if lower == "range" || lower == "content-type" || lower == "content-md5" {
+			headerNames = append(headerNames, lower)
+		}
+	}
+	sort.Strings(headerNames)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range headerNames {
+		value := r.Header.Get(name)
+		if name == "host" {
+			value = r.URL.Host
+		}
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(value) + "\n")
+	}
+	signedHeaders := strings.Join(headerNames, ";")
+
+	canonicalRequest := strings.Join([]string{
+		r.Method,
+		canonicalURI(r.URL),
+		canonicalQuery(r.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := s.scope(now)
+	signature := s.signature(now, stringToSign(amzDate, scope, canonicalRequest))
+
+	r.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		algorithm, s.accessKey, scope, signedHeaders, signature))
+}
+
+//presign returns url which is valid for ttl without any additional headers
+func (s signer) presign(method string, u *url.URL, ttl time.Duration, now time.Time) string {
+	now = now.UTC()
+	amzDate := now.Format(amzDateFormat)
+	scope := s.scope(now)
+
+	q := u.Query()
+	q.Set("X-Amz-Algorithm", algorithm)
+	q.Set("X-Amz-Credential", s.accessKey+"/"+scope)
+	q.Set("X-Amz-Date", amzDate)
+	q.Set("X-Amz-Expires", strconv.FormatInt(int64(ttl/time.Second), 10))
+	q.Set("X-Amz-SignedHeaders", "host")
+
+	canonicalRequest := strings.Join([]string{
+		method,
+		canonicalURI(u),
+		canonicalQuery(q),
+		"host:" + u.Host + "\n",
+		"host",
+		unsignedPayload,
+	}, "\n")
+
+	signature := s.signature(now, stringToSign(amzDate, scope, canonicalRequest))
+
+	signed := *u
+	signed.RawQuery = canonicalQuery(q) + "&X-Amz-Signature=" + signature
+	return signed.String()
+}
+
+func (s signer) scope(now time.Time) string {
+	return strings.Join([]string{now.Format(shortDateFormat), s.region, service, "aws4_request"}, "/")
+}
+
+func (s signer) signature(now time.Time, stringToSign string) string {
+	key := hmacSHA256([]byte("AWS4"+s.secretKey), now.Format(shortDateFormat))
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+func stringToSign(amzDate, scope, canonicalRequest string) string {
+	return strings.Join([]string{algorithm, amzDate, scope, hashHex([]byte(canonicalRequest))}, "\n")
+}
+
+func canonicalURI(u *url.URL) string {
+	path := u.Path
+	if path == "" {
+		return "/"
+	}
+	segments := strings.Split(path, "/")
+	for i, s := range segments {
+		segments[i] = uriEncode(s)
+	}
+	return strings.Join(segments, "/")
+}
+
+func canonicalQuery(q url.Values) string {
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		vv := q[k]
+		sort.Strings(vv)
+		for _, v := range vv {
+			pairs = append(pairs, uriEncode(k)+"="+uriEncode(v))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+//uriEncode encodes everything except unreserved characters as required by sigv4
+func uriEncode(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func hashHex(data []byte) string {
+	h := sha256.Sum256(data)
+	return hex.EncodeToString(h[:])
+}
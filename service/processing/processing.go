@@ -0,0 +1,266 @@
+// Package processing normalizes loudness, trims silence and changes speed of downloaded audio with ffmpeg.
+// Implements core.ProcessingService
+package processing
+
+import (
+	"context"
+	"fmt"
+	"github.com/htim/youpod/core"
+	"github.com/htim/youpod/service/ffmpeg"
+	"github.com/pkg/errors"
+	"github.com/rs/xid"
+	log "github.com/sirupsen/logrus"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	//loudnorm targets podcast loudness recommended by most platforms
+	loudnorm = "loudnorm=I=-16:TP=-1.5:LRA=11"
+
+	//only dead air longer than 2 seconds is trimmed
+	silencedetect = "silencedetect=noise=-50dB:d=2"
+
+	//silenceMargin of the silence is kept, so speech does not start abruptly
+	silenceMargin = 0.5
+
+	//loudnorm upsamples audio to 192 kHz internally
+	sampleRate = 48000
+)
+
+type Service struct {
+	outputDir string
+}
+
+func NewService(outputDir string) (*Service, error) {
+	if err := ffmpeg.Check(); err != nil {
+		return nil, err
+	}
+	return &Service{outputDir: outputDir}, nil
+}
+
+//Process applies owner's processing settings. File is returned as is when there is nothing to do
+func (s *Service) Process(ctx context.Context, owner core.User, f core.File) (core.File, error) {
+	p := owner.Processing()
+	if p.Empty() {
+		return f, nil
+	}
+
+	id := xid.New().String()
+	ext := core.Extension(f.ContentType)
+	target := s.path(id, ext)
+
+	processed := false
+	defer func() {
+		if !processed {
+			s.remove(id, ext, "source")
+		}
+	}()
+
+	source, err := ffmpeg.Input(f.Content, s.path(id, "source"))
+	if err != nil {
+		return core.File{}, err
+	}
+
+	info, err := ffmpeg.Probe(ctx, source)
+	if err != nil {
+		return core.File{}, errors.Wrap(err, "cannot probe file")
+	}
+
+	loud, detect := "", ""
+	if p.Normalize {
+		loud = loudnorm
+	}
+	if p.TrimSilence {
+		detect = silencedetect
+	}
+
+	//single decoding pass measures loudness and finds silence
+	a, err := ffmpeg.Analyze(ctx, source, loud, detect)
+	if err != nil {
+		return core.File{}, errors.Wrap(err, "cannot analyze file")
+	}
+
+	filters := make([]string, 0)
+	steps := make([]string, 0)
+
+	var trimStart, trimEnd float64
+
+	//silence at the end is cut with atrim by analysis results instead of silenceremove,
+	//which can only remove trailing silence by reversing the whole file in memory
+	if p.TrimSilence {
+		if start, end, ok := edges(a.Silences, info.Duration()); ok {
+			trimStart, trimEnd = start, end
+			trim := fmt.Sprintf("atrim=start=%.2f", start)
+			if end > 0 {
+				trim += fmt.Sprintf(":end=%.2f", end)
+			}
+			filters = append(filters, trim, "asetpts=PTS-STARTPTS")
+			steps = append(steps, "silence_trim")
+		}
+	}
+
+	if p.Normalize {
+		l := a.Loudness
+		filters = append(filters, fmt.Sprintf("%s:measured_I=%s:measured_TP=%s:measured_LRA=%s:measured_thresh=%s:offset=%s:linear=true",
+			loudnorm, l.InputI, l.InputTP, l.InputLRA, l.InputThresh, l.TargetOffset), fmt.Sprintf("aresample=%d", sampleRate))
+		steps = append(steps, "loudnorm")
+	}
+
+	if p.Speed != 1 {
+		speed := strconv.FormatFloat(p.Speed, 'f', -1, 64)
+		filters = append(filters, "atempo="+speed)
+		steps = append(steps, "atempo="+speed)
+	}
+
+	if len(filters) == 0 {
+		return f, nil
+	}
+
+	format := core.AudioFormat{Codec: ext, Bitrate: owner.AudioFormat().Bitrate}
+	if err := ffmpeg.Filter(ctx, source, target, strings.Join(filters, ","), format); err != nil {
+		return core.File{}, errors.Wrap(err, "cannot filter file")
+	}
+
+	result, err := ffmpeg.Probe(ctx, target)
+	if err != nil {
+		return core.File{}, errors.Wrap(err, "cannot probe processed file")
+	}
+
+	content, err := os.Open(target)
+	if err != nil {
+		return core.File{}, errors.Wrap(err, "cannot open processed file")
+	}
+
+	fileInfo, err := content.Stat()
+	if err != nil {
+		_ = content.Close()
+		return core.File{}, errors.Wrap(err, "cannot get file info")
+	}
+
+	processed = true
+	s.remove(id, "source")
+
+	m := f.Metadata
+	m.TmpFileID = id
+	m.Size = fileInfo.Size()
+	m.Duration = result.Duration()
+	m.Processing = append(append([]string{}, f.Processing...), steps...)
+	at := timeline(trimStart, trimEnd, p.Speed)
+	m.Chapters = retimeChapters(f.Chapters, at)
+
+	log.WithField("user", owner.Username).Debugf("processed %s: %s", f.Name, strings.Join(steps, ", "))
+
+	return core.File{
+		Metadata:   m,
+		Content:    content,
+		Transcript: retimeCues(f.Transcript, at),
+	}, nil
+}
+
+//Cleanup removes files produced by Process, files returned as is are left to their extractors
+func (s *Service) Cleanup(f core.File) {
+	if len(f.Processing) == 0 {
+		return
+	}
+	if err := f.Content.Close(); err != nil {
+		log.WithError(err).Debug("file is already closed")
+	}
+	s.remove(f.TmpFileID, core.Extension(f.ContentType))
+}
+
+//edges finds where audio starts and ends without silence, end is zero when audio does not end with silence
+func edges(silences []ffmpeg.Silence, duration int64) (float64, float64, bool) {
+	if len(silences) == 0 {
+		return 0, 0, false
+	}
+
+	start, end := 0.0, 0.0
+
+	first := silences[0]
+	if first.Start <= 0.1 && first.End > 0 {
+		start = first.End - silenceMargin
+		if start < 0 {
+			start = 0
+		}
+	}
+
+	last := silences[len(silences)-1]
+	if last.Start > start && (last.End == 0 || last.End >= float64(duration)) {
+		end = last.Start + silenceMargin
+	}
+
+	return start, end, start > 0 || end > 0
+}
+
+//timeline maps time of the original audio to time of trimmed and sped up one
+func timeline(start, end, speed float64) func(float64) float64 {
+	return func(t float64) float64 {
+		if end > 0 && t > end {
+			t = end
+		}
+		t -= start
+		if t < 0 {
+			t = 0
+		}
+		return t / speed
+	}
+}
+
+//retimeChapters drops chapters inside trimmed silence
+func retimeChapters(chapters []core.Chapter, at func(float64) float64) []core.Chapter {
+	if len(chapters) == 0 {
+		return chapters
+	}
+
+	cc := make([]core.Chapter, 0, len(chapters))
+	for _, c := range chapters {
+		r := core.Chapter{
+			Title: c.Title,
+			Start: at(c.Start),
+		}
+		//zero end means the chapter lasts until the next one
+		if c.End != 0 {
+			if r.End = at(c.End); r.End <= r.Start {
+				continue
+			}
+		}
+		cc = append(cc, r)
+	}
+	return cc
+}
+
+//retimeCues drops cues inside trimmed silence
+func retimeCues(cues []core.Cue, at func(float64) float64) []core.Cue {
+	if len(cues) == 0 {
+		return cues
+	}
+
+	cc := make([]core.Cue, 0, len(cues))
+	for _, c := range cues {
+		r := core.Cue{
+			Start: at(c.Start),
+			End:   at(c.End),
+			Text:  c.Text,
+		}
+		if r.End <= r.Start {
+			continue
+		}
+		cc = append(cc, r)
+	}
+	return cc
+}
+
+func (s *Service) remove(id string, exts ...string) {
+	for _, ext := range exts {
+		file := s.path(id, ext)
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			log.WithError(err).Debugf("cannot remove file: %s", file)
+		}
+	}
+}
+
+func (s *Service) path(id, ext string) string {
+	return fmt.Sprintf("%s/%s.%s", s.outputDir, id, ext)
+}
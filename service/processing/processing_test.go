@@ -0,0 +1,54 @@
+package processing
+
+import (
+	"github.com/htim/youpod/core"
+	"github.com/htim/youpod/service/ffmpeg"
+	"testing"
+)
+
+func TestEdges(t *testing.T) {
+	cases := []struct {
+		name       string
+		silences   []ffmpeg.Silence
+		duration   int64
+		start, end float64
+		ok         bool
+	}{
+		{"no silence", nil, 100, 0, 0, false},
+		{"leading", []ffmpeg.Silence{{Start: 0, End: 5}}, 100, 4.5, 0, true},
+		{"trailing till the end", []ffmpeg.Silence{{Start: 90, End: 0}}, 100, 0, 90.5, true},
+		{"trailing with end reported", []ffmpeg.Silence{{Start: 90, End: 100.02}}, 100, 0, 90.5, true},
+		{"both", []ffmpeg.Silence{{Start: 0, End: 3}, {Start: 40, End: 45}, {Start: 95}}, 100, 2.5, 95.5, true},
+		{"only in the middle", []ffmpeg.Silence{{Start: 40, End: 45}}, 100, 0, 0, false},
+		{"all silent", []ffmpeg.Silence{{Start: 0}}, 100, 0, 0, false},
+	}
+
+	for _, c := range cases {
+		start, end, ok := edges(c.silences, c.duration)
+		if start != c.start || end != c.end || ok != c.ok {
+			t.Errorf("%s: expected (%v, %v, %v), got (%v, %v, %v)", c.name, c.start, c.end, c.ok, start, end, ok)
+		}
+	}
+}
+
+func TestRetimeChapters(t *testing.T) {
+	cc := retimeChapters([]core.Chapter{
+		{Title: "Silence", Start: 0, End: 10},
+		{Title: "Intro", Start: 10, End: 70},
+		{Title: "Talk", Start: 70, End: 200},
+	}, timeline(10, 150, 2))
+
+	expected := []core.Chapter{
+		{Title: "Intro", Start: 0, End: 30},
+		{Title: "Talk", Start: 30, End: 70},
+	}
+
+	if len(cc) != len(expected) {
+		t.Fatalf("expected %d chapters, got %v", len(expected), cc)
+	}
+	for i := range cc {
+		if cc[i] != expected[i] {
+			t.Errorf("expected %v, got %v", expected[i], cc[i])
+		}
+	}
+}
@@ -0,0 +1,512 @@
+// Package queue processes user links with a bounded pool of workers, unfinished jobs are resumed after restart.
+// Implements core.JobQueue
+package queue
+
+import (
+	"context"
+	"github.com/htim/youpod"
+	"github.com/htim/youpod/core"
+	"github.com/pkg/errors"
+	"github.com/rs/xid"
+	log "github.com/sirupsen/logrus"
+	"sync"
+	"time"
+)
+
+//stages taking longer fail the job
+const (
+	identifyTimeout   = time.Minute
+	downloadTimeout   = time.Hour
+	processingTimeout = 30 * time.Minute
+	taggingTimeout    = 10 * time.Minute
+	uploadTimeout     = time.Hour
+)
+
+type Service struct {
+	jobRepository      core.JobRepository
+	userRepository     core.UserRepository
+	feedRepository     core.FeedRepository
+	metadataRepository core.MetadataRepository
+	sourceService      core.SourceService
+	uploadService      core.UploadService
+	mediaService       core.MediaService
+
+	processingService core.ProcessingService
+	taggingService    core.TaggingService
+
+	workers int
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	pending []core.Job
+	running map[string]running //by job ID
+
+	statesMu   sync.Mutex
+	statesCond *sync.Cond
+	states     []core.Job //state changes not yet taken by the consumer, they are never dropped
+	updates    chan core.Job
+}
+
+func NewService(
+	jobRepository core.JobRepository,
+	userRepository core.UserRepository,
+	feedRepository core.FeedRepository,
+	metadataRepository core.MetadataRepository,
+	sourceService core.SourceService,
+	uploadService core.UploadService,
+	mediaService core.MediaService,
+	processingService core.ProcessingService,
+	taggingService core.TaggingService,
+	workers int,
+) *Service {
+	if workers < 1 {
+		workers = 1
+	}
+
+	s := &Service{
+		jobRepository:      jobRepository,
+		userRepository:     userRepository,
+		feedRepository:     feedRepository,
+		metadataRepository: metadataRepository,
+		sourceService:      sourceService,
+		uploadService:      uploadService,
+		mediaService:       mediaService,
+
+		processingService: processingService,
+		taggingService:    taggingService,
+
+		workers: workers,
+		pending: make([]core.Job, 0),
+		running: make(map[string]running),
+		states:  make([]core.Job, 0),
+		updates: make(chan core.Job, 100),
+	}
+	s.cond = sync.NewCond(&s.mu)
+	s.statesCond = sync.NewCond(&s.statesMu)
+
+	go s.forward()
+
+	return s
+}
+
+//Run requeues jobs interrupted by previous shutdown and starts workers
+func (s *Service) Run() error {
+	unfinished, err := s.jobRepository.FindJobsByState(context.Background(),
+		core.JobQueued,
+		core.JobDownloading,
+		core.JobProcessing,
+		core.JobUploading,
+	)
+	if err != nil {
+		return errors.Wrap(err, "cannot load unfinished jobs")
+	}
+
+	for _, j := range unfinished {
+		log.WithField("job", j.ID).Debug("resuming job")
+		j.State = core.JobQueued
+		s.push(j)
+	}
+
+	for i := 0; i < s.workers; i++ {
+		go s.work()
+	}
+
+	return nil
+}
+
+func (s *Service) Enqueue(owner core.User, chatID int64, feed string, link string) (core.Job, error) {
+	j := newJob(owner, chatID, feed, link)
+
+	if err := s.jobRepository.SaveJob(context.Background(), j); err != nil {
+		return core.Job{}, errors.Wrapf(err, "cannot save job (user ID '%s')", owner.Username)
+	}
+
+	s.push(j)
+
+	return j, nil
+}
+
+func (s *Service) EnqueuePlaylist(owner core.User, chatID int64, feed string, p core.Playlist) ([]core.Job, error) {
+	jobs := make([]core.Job, 0, len(p.Entries))
+
+	for _, e := range p.Entries {
+		j := newJob(owner, chatID, feed, e.Link)
+		j.Title = e.Title
+		j.Playlist = p.Title
+
+		if err := s.jobRepository.SaveJob(context.Background(), j); err != nil {
+			return jobs, errors.Wrapf(err, "cannot save job (user ID '%s', link '%s')", owner.Username, e.Link)
+		}
+
+		s.push(j)
+		jobs = append(jobs, j)
+	}
+
+	return jobs, nil
+}
+
+func (s *Service) EnqueueUpload(owner core.User, chatID int64, feed string, u core.Upload) (core.Job, error) {
+	j := newJob(owner, chatID, feed, "")
+	j.Upload = &u
+	j.Title = u.Title
+	if j.Title == "" {
+		j.Title = u.FileName
+	}
+
+	if err := s.jobRepository.SaveJob(context.Background(), j); err != nil {
+		return core.Job{}, errors.Wrapf(err, "cannot save job (user ID '%s')", owner.Username)
+	}
+
+	s.push(j)
+
+	return j, nil
+}
+
+//Cancel removes queued jobs of the owner and aborts running ones. Running jobs are reported as cancelled
+//by their workers once their commands are killed and temporary files are removed
+func (s *Service) Cancel(owner core.User) []core.Job {
+	s.mu.Lock()
+	queued := make([]core.Job, 0)
+	pending := make([]core.Job, 0, len(s.pending))
+	for _, j := range s.pending {
+		if j.Username == owner.Username {
+			queued = append(queued, j)
+		} else {
+			pending = append(pending, j)
+		}
+	}
+	s.pending = pending
+
+	cancelled := make([]core.Job, 0)
+	for _, r := range s.running {
+		if r.job.Username == owner.Username {
+			r.cancel()
+			cancelled = append(cancelled, r.job)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, j := range queued {
+		cancelled = append(cancelled, s.transition(j, core.JobCancelled))
+	}
+
+	return cancelled
+}
+
+func (s *Service) Updates() <-chan core.Job {
+	return s.updates
+}
+
+func newJob(owner core.User, chatID int64, feed string, link string) core.Job {
+	now := time.Now()
+	return core.Job{
+		ID:        xid.New().String(),
+		Username:  owner.Username,
+		ChatID:    chatID,
+		Link:      link,
+		Feed:      feed,
+		State:     core.JobQueued,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+func (s *Service) push(j core.Job) {
+	s.mu.Lock()
+	s.pending = append(s.pending, j)
+	s.mu.Unlock()
+	s.cond.Signal()
+}
+
+type running struct {
+	job    core.Job
+	cancel context.CancelFunc
+}
+
+//pop registers the job as running at once, so it cannot be missed by Cancel
+func (s *Service) pop() (core.Job, context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for len(s.pending) == 0 {
+		s.cond.Wait()
+	}
+	j := s.pending[0]
+	s.pending = s.pending[1:]
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.running[j.ID] = running{job: j, cancel: cancel}
+
+	return j, ctx
+}
+
+func (s *Service) work() {
+	for {
+		j, ctx := s.pop()
+		s.process(ctx, j)
+
+		s.mu.Lock()
+		s.running[j.ID].cancel()
+		delete(s.running, j.ID)
+		s.mu.Unlock()
+	}
+}
+
+//process runs stages with their own timeouts, ctx is cancelled when the job is cancelled
+func (s *Service) process(ctx context.Context, j core.Job) {
+	user, err := s.userRepository.FindUserByUsername(ctx, j.Username)
+	if err != nil {
+		s.fail(ctx, j, errors.Wrap(err, "cannot find job owner"))
+		return
+	}
+
+	feed, err := s.findFeed(ctx, user, j.Feed)
+	if err != nil {
+		s.fail(ctx, j, err)
+		return
+	}
+
+	//the same original is not downloaded twice
+	sourceID := s.sourceID(ctx, j)
+	if m, ok := s.existing(ctx, user, sourceID); ok {
+		s.duplicate(ctx, user, feed, j, m)
+		return
+	}
+
+	j = s.transition(j, core.JobDownloading)
+
+	downloadCtx, cancel := context.WithTimeout(ctx, downloadTimeout)
+	file, cleanup, err := s.download(downloadCtx, user, j)
+	cancel()
+	if err != nil {
+		s.fail(ctx, j, err)
+		return
+	}
+	defer cleanup(file)
+
+	j.Title = file.Name
+	file.SourceID = sourceID
+
+	if !user.Processing().Empty() {
+		j = s.transition(j, core.JobProcessing)
+
+		processingCtx, cancel := context.WithTimeout(ctx, processingTimeout)
+		processed, err := s.processingService.Process(processingCtx, user, file)
+		cancel()
+		if err != nil {
+			s.fail(ctx, j, errors.Wrap(err, "cannot process audio"))
+			return
+		}
+		defer s.processingService.Cleanup(processed)
+
+		file = processed
+	}
+
+	taggingCtx, cancel := context.WithTimeout(ctx, taggingTimeout)
+	tagged, err := s.taggingService.Tag(taggingCtx, user, feed, file)
+	cancel()
+	if err != nil {
+		s.fail(ctx, j, errors.Wrap(err, "cannot tag audio"))
+		return
+	}
+	defer s.taggingService.Cleanup(tagged)
+
+	file = tagged
+
+	j = s.transition(j, core.JobUploading)
+
+	file.Content = s.progress(j).Reader(file.Content, file.Size)
+
+	uploadCtx, cancel := context.WithTimeout(ctx, uploadTimeout)
+	id, err := s.mediaService.SaveFile(uploadCtx, user, file)
+	cancel()
+	if errors.Cause(err) == youpod.ErrFileExists {
+		//another job of the same original has finished first
+		if m, ok := s.existing(context.Background(), user, sourceID); ok {
+			s.duplicate(context.Background(), user, feed, j, m)
+			return
+		}
+	}
+	if err != nil {
+		s.fail(ctx, j, errors.Wrap(err, "cannot save media"))
+		return
+	}
+
+	//saved file is added to the feed even if the job has been cancelled meanwhile, so it is not lost
+	if err = s.addToFeed(context.Background(), user, feed, id); err != nil {
+		s.fail(ctx, j, err)
+		return
+	}
+
+	j.FileID = id
+	s.transition(j, core.JobDone)
+}
+
+//download fetches job source and returns function to remove temporary files
+func (s *Service) download(ctx context.Context, user core.User, j core.Job) (core.File, func(core.File), error) {
+	if j.Upload != nil {
+		file, err := s.uploadService.Import(ctx, user, *j.Upload)
+		if err != nil {
+			return core.File{}, nil, errors.Wrap(err, "cannot import uploaded file")
+		}
+		return file, s.uploadService.Cleanup, nil
+	}
+
+	file, err := s.sourceService.Download(ctx, user, j.Link, s.progress(j))
+	if err != nil {
+		return core.File{}, nil, errors.Wrap(err, "cannot download link")
+	}
+	return file, s.sourceService.Cleanup, nil
+}
+
+//sourceID identifies job link, links which cannot be identified are processed anyway
+func (s *Service) sourceID(ctx context.Context, j core.Job) string {
+	if j.Upload != nil {
+		return ""
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, identifyTimeout)
+	defer cancel()
+
+	id, err := s.sourceService.ID(ctx, j.Link)
+	if err != nil {
+		log.WithError(err).WithField("job", j.ID).Warn("cannot identify link, duplicates are not detected")
+		return ""
+	}
+	return id
+}
+
+//existing returns file of the user downloaded from the same original
+func (s *Service) existing(ctx context.Context, user core.User, sourceID string) (core.Metadata, bool) {
+	if sourceID == "" {
+		return core.Metadata{}, false
+	}
+
+	m, err := s.metadataRepository.FindFileMetadataBySource(ctx, user.Username, sourceID)
+	if err != nil {
+		if err != youpod.ErrMetadataNotFound {
+			log.WithError(err).WithField("user", user.Username).Errorf("cannot find file of '%s'", sourceID)
+		}
+		return core.Metadata{}, false
+	}
+	return m, true
+}
+
+//duplicate adds already saved file to the feed of the job unless it is there already
+func (s *Service) duplicate(ctx context.Context, user core.User, feed core.Feed, j core.Job, m core.Metadata) {
+	log.WithField("job", j.ID).WithField("user", j.Username).Debugf("'%s' is already saved as '%s'", m.SourceID, m.FileID)
+
+	if !inFeed(user, feed, m.FileID) {
+		if err := s.addToFeed(ctx, user, feed, m.FileID); err != nil {
+			s.fail(ctx, j, err)
+			return
+		}
+	}
+
+	j.Title = m.Name
+	j.FileID = m.FileID
+	s.transition(j, core.JobDuplicate)
+}
+
+func inFeed(user core.User, f core.Feed, fileID string) bool {
+	if f.Name != core.MainFeed {
+		return f.HasFile(fileID)
+	}
+	for _, id := range user.Files {
+		if id == fileID {
+			return true
+		}
+	}
+	return false
+}
+
+//findFeed falls back to the main feed when the named one has been removed since the job was enqueued
+func (s *Service) findFeed(ctx context.Context, user core.User, name string) (core.Feed, error) {
+	if name != "" && name != core.MainFeed {
+		f, err := s.feedRepository.FindFeed(ctx, user.Username, name)
+		if err == nil {
+			return f, nil
+		}
+		if err != youpod.ErrFeedNotFound {
+			return core.Feed{}, errors.Wrapf(err, "cannot find feed '%s'", name)
+		}
+		log.WithField("user", user.Username).Warnf("feed '%s' is not found, adding file to the main feed", name)
+	}
+
+	return core.Feed{Username: user.Username, Name: core.MainFeed}, nil
+}
+
+func (s *Service) addToFeed(ctx context.Context, user core.User, f core.Feed, fileID string) error {
+	if f.Name != core.MainFeed {
+		return errors.Wrapf(s.feedRepository.AddFileToFeed(ctx, f, fileID), "cannot update feed '%s' file list", f.Name)
+	}
+
+	return errors.Wrap(s.userRepository.AddFileToUser(ctx, user, fileID), "cannot update user file list")
+}
+
+//fail reports job cancelled instead of failed if ctx of the job is cancelled
+func (s *Service) fail(ctx context.Context, j core.Job, err error) {
+	if ctx.Err() == context.Canceled {
+		log.WithField("job", j.ID).WithField("user", j.Username).Info("job cancelled")
+		s.transition(j, core.JobCancelled)
+		return
+	}
+
+	log.WithError(err).WithField("job", j.ID).WithField("user", j.Username).Error("job failed")
+	j.Error = err.Error()
+	s.transition(j, core.JobFailed)
+}
+
+//progress publishes bytes done in the current state of the job. Progress is dropped rather than
+//blocking the job when nobody keeps up with updates, and it never overtakes state changes waiting for the consumer
+func (s *Service) progress(j core.Job) core.Progress {
+	return func(done, total int64) {
+		j.Done, j.Total = done, total
+
+		s.statesMu.Lock()
+		defer s.statesMu.Unlock()
+		if len(s.states) > 0 || len(s.updates) >= cap(s.updates)/2 {
+			return
+		}
+		select {
+		case s.updates <- j:
+		default:
+		}
+	}
+}
+
+//transition persists new job state and publishes it to updates channel. Workers do not wait for the consumer,
+//state changes are kept until the consumer takes them
+func (s *Service) transition(j core.Job, state core.JobState) core.Job {
+	j.State = state
+	j.UpdatedAt = time.Now()
+
+	if err := s.jobRepository.SaveJob(context.Background(), j); err != nil {
+		log.WithError(err).WithField("job", j.ID).Error("cannot save job state")
+	}
+
+	s.statesMu.Lock()
+	s.states = append(s.states, j)
+	s.statesMu.Unlock()
+	s.statesCond.Signal()
+
+	return j
+}
+
+//forward sends state changes to updates channel in order. The change being sent stays in the list,
+//so progress does not overtake it
+func (s *Service) forward() {
+	for {
+		s.statesMu.Lock()
+		for len(s.states) == 0 {
+			s.statesCond.Wait()
+		}
+		j := s.states[0]
+		s.statesMu.Unlock()
+
+		s.updates <- j
+
+		s.statesMu.Lock()
+		s.states = s.states[1:]
+		s.statesMu.Unlock()
+	}
+}
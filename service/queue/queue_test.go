@@ -0,0 +1,111 @@
+package queue
+
+import (
+	"context"
+	"github.com/htim/youpod"
+	"github.com/htim/youpod/core"
+	"testing"
+)
+
+type userRepository struct {
+	core.UserRepository
+	user core.User
+}
+
+func (r *userRepository) FindUserByUsername(ctx context.Context, username string) (core.User, error) {
+	return r.user, nil
+}
+
+func (r *userRepository) AddFileToUser(ctx context.Context, u core.User, fileID string) error {
+	r.user.Files = append(r.user.Files, fileID)
+	return nil
+}
+
+type feedRepository struct {
+	core.FeedRepository
+	feed core.Feed
+}
+
+func (r *feedRepository) FindFeed(ctx context.Context, username, name string) (core.Feed, error) {
+	if name != r.feed.Name {
+		return core.Feed{}, youpod.ErrFeedNotFound
+	}
+	return r.feed, nil
+}
+
+func (r *feedRepository) AddFileToFeed(ctx context.Context, f core.Feed, fileID string) error {
+	r.feed.Files = append(r.feed.Files, fileID)
+	return nil
+}
+
+type metadataRepository struct {
+	core.MetadataRepository
+	metadata core.Metadata
+}
+
+func (r *metadataRepository) FindFileMetadataBySource(ctx context.Context, username, sourceID string) (core.Metadata, error) {
+	if username != r.metadata.Username || sourceID != r.metadata.SourceID {
+		return core.Metadata{}, youpod.ErrMetadataNotFound
+	}
+	return r.metadata, nil
+}
+
+type jobRepository struct {
+	core.JobRepository
+}
+
+func (r *jobRepository) SaveJob(ctx context.Context, j core.Job) error {
+	return nil
+}
+
+type sourceService struct {
+	core.SourceService
+	id string
+}
+
+func (s *sourceService) ID(ctx context.Context, link string) (string, error) {
+	return s.id, nil
+}
+
+func TestDuplicateIsAddedToRequestedFeed(t *testing.T) {
+	user := core.User{Username: "user", Files: []string{"file"}}
+	users := &userRepository{user: user}
+	feeds := &feedRepository{feed: core.Feed{Username: "user", Name: "talks"}}
+	metadata := &metadataRepository{metadata: core.Metadata{FileID: "file", Username: "user", SourceID: "youtube:abc", Name: "Talk"}}
+
+	s := NewService(&jobRepository{}, users, feeds, metadata, &sourceService{id: "youtube:abc"}, nil, nil, nil, nil, 1)
+
+	//the file saved to the main feed is sent to another feed twice
+	for i := 0; i < 2; i++ {
+		s.process(context.Background(), newJob(user, 1, "talks", "https://youtu.be/abc"))
+
+		j := <-s.updates
+		if j.State != core.JobDuplicate || j.FileID != "file" {
+			t.Fatalf("expected duplicate of 'file', actual %s of '%s' (%s)", j.State, j.FileID, j.Error)
+		}
+
+		if len(feeds.feed.Files) != 1 || feeds.feed.Files[0] != "file" {
+			t.Errorf("expected file to be added to the feed once, actual files: %v", feeds.feed.Files)
+		}
+	}
+
+	if len(users.user.Files) != 1 {
+		t.Errorf("main feed must not change, actual files: %v", users.user.Files)
+	}
+}
+
+func TestStateChangesAreNotDropped(t *testing.T) {
+	s := NewService(&jobRepository{}, nil, nil, nil, nil, nil, nil, nil, nil, 1)
+
+	//cancelling a long playlist publishes more changes than the channel holds
+	n := 3 * cap(s.updates)
+	for i := 0; i < n; i++ {
+		s.transition(newJob(core.User{Username: "user"}, 1, "", "https://youtu.be/abc"), core.JobCancelled)
+	}
+
+	for i := 0; i < n; i++ {
+		if j := <-s.updates; j.State != core.JobCancelled {
+			t.Fatalf("expected cancelled job, actual %s", j.State)
+		}
+	}
+}
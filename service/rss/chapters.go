@@ -0,0 +1,41 @@
+package rss
+
+import (
+	"encoding/json"
+	"github.com/htim/youpod/core"
+	"github.com/pkg/errors"
+)
+
+const chaptersType = "application/json+chapters"
+
+//chapters follows https://github.com/Podcastindex-org/podcast-namespace/blob/main/chapters/jsonChapters.md
+type chapters struct {
+	Version  string    `json:"version"`
+	Chapters []chapter `json:"chapters"`
+}
+
+type chapter struct {
+	StartTime float64 `json:"startTime"`
+	EndTime   float64 `json:"endTime,omitempty"`
+	Title     string  `json:"title"`
+}
+
+func (s *service) Chapters(m core.Metadata) ([]byte, error) {
+	c := chapters{
+		Version:  "1.2.0",
+		Chapters: make([]chapter, 0, len(m.Chapters)),
+	}
+	for _, ch := range m.Chapters {
+		c.Chapters = append(c.Chapters, chapter{
+			StartTime: ch.Start,
+			EndTime:   ch.End,
+			Title:     ch.Title,
+		})
+	}
+
+	bb, err := json.Marshal(c)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot marshal chapters")
+	}
+	return bb, nil
+}
@@ -0,0 +1,116 @@
+package rss
+
+import (
+	"bytes"
+	"github.com/htim/youpod/core"
+	"html"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+//summaryLength is the limit of itunes:summary in characters
+const summaryLength = 4000
+
+//links and timestamps in a description, links go first, so timestamps inside of them are not matched
+var descriptionPattern = regexp.MustCompile(`(https?://[^\s<>"]+)|\b((?:\d{1,2}:)?\d{1,2}:[0-5]\d)\b`)
+
+//descriptionHTML renders description as HTML: text is escaped, links and timestamps become clickable
+func descriptionHTML(m core.Metadata) string {
+	text := m.Description
+	if text == "" {
+		text = m.Name
+	}
+
+	var buf bytes.Buffer
+	last := 0
+	for _, match := range descriptionPattern.FindAllStringSubmatchIndex(text, -1) {
+		start, end := match[0], match[1]
+		var href string
+		if match[2] >= 0 {
+			//punctuation after a link is part of the sentence
+			end = start + len(strings.TrimRight(text[start:end], ".,;:!?)'"))
+			href = text[start:end]
+		} else {
+			href = timestampLink(m.URL, text[start:end])
+		}
+		if href == "" {
+			continue
+		}
+		buf.WriteString(escape(text[last:start]))
+		link(&buf, href, text[start:end])
+		last = end
+	}
+	buf.WriteString(escape(text[last:]))
+
+	if m.URL != "" || m.ChannelURL != "" {
+		buf.WriteString("<br><br>")
+		if m.URL != "" {
+			link(&buf, m.URL, "Original")
+		}
+		if m.URL != "" && m.ChannelURL != "" {
+			buf.WriteString(" | ")
+		}
+		if m.ChannelURL != "" {
+			link(&buf, m.ChannelURL, "Channel")
+		}
+	}
+
+	return buf.String()
+}
+
+//summary is a plain text description truncated to summaryLength
+func summary(m core.Metadata) string {
+	text := strings.TrimSpace(m.Description)
+	if text == "" {
+		return m.Name
+	}
+	if utf8.RuneCountInString(text) <= summaryLength {
+		return text
+	}
+
+	cut := string([]rune(text)[:summaryLength-1])
+	//do not cut a word in half if there is a space close enough
+	if i := strings.LastIndexAny(cut, " \n"); i > len(cut)-100 {
+		cut = strings.TrimSpace(cut[:i])
+	}
+	return cut + "…"
+}
+
+//timestampLink points to the moment of the original YouTube video, empty for other sources:
+//timestamps in a description refer to the original timeline, which post-processing may change
+func timestampLink(original, timestamp string) string {
+	u, err := url.Parse(original)
+	if err != nil {
+		return ""
+	}
+	host := strings.TrimPrefix(u.Hostname(), "www.")
+	if host != "youtube.com" && host != "m.youtube.com" && host != "youtu.be" {
+		return ""
+	}
+
+	seconds := 0
+	for _, part := range strings.Split(timestamp, ":") {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return ""
+		}
+		seconds = seconds*60 + n
+	}
+
+	q := u.Query()
+	q.Set("t", strconv.Itoa(seconds)+"s")
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+func link(buf *bytes.Buffer, href, text string) {
+	buf.WriteString(`<a href="` + html.EscapeString(href) + `">` + html.EscapeString(text) + `</a>`)
+}
+
+//escape escapes text and keeps line breaks
+func escape(text string) string {
+	return strings.Replace(html.EscapeString(text), "\n", "<br>", -1)
+}
@@ -0,0 +1,52 @@
+package rss
+
+import (
+	"github.com/htim/youpod/core"
+	"strings"
+	"testing"
+)
+
+func TestDescriptionHTML(t *testing.T) {
+	m := core.Metadata{
+		Name:        "title",
+		URL:         "https://www.youtube.com/watch?v=abc",
+		ChannelURL:  "https://www.youtube.com/channel/xyz",
+		Description: "Intro <b>&</b>\n01:05 Part one\n1:02:03 Part two\nSee https://example.org/a?b=1&c=2.",
+	}
+
+	expected := `Intro &lt;b&gt;&amp;&lt;/b&gt;<br>` +
+		`<a href="https://www.youtube.com/watch?t=65s&amp;v=abc">01:05</a> Part one<br>` +
+		`<a href="https://www.youtube.com/watch?t=3723s&amp;v=abc">1:02:03</a> Part two<br>` +
+		`See <a href="https://example.org/a?b=1&amp;c=2">https://example.org/a?b=1&amp;c=2</a>.` +
+		`<br><br><a href="https://www.youtube.com/watch?v=abc">Original</a> | <a href="https://www.youtube.com/channel/xyz">Channel</a>`
+
+	if actual := descriptionHTML(m); actual != expected {
+		t.Errorf("expected:\n%s\nactual:\n%s", expected, actual)
+	}
+}
+
+func TestDescriptionHTMLOtherSource(t *testing.T) {
+	m := core.Metadata{
+		Name:        "title",
+		Description: "12:30 is not a link without a YouTube video",
+	}
+
+	if actual := descriptionHTML(m); actual != m.Description {
+		t.Errorf("expected plain text, actual: %s", actual)
+	}
+}
+
+func TestSummary(t *testing.T) {
+	if s := summary(core.Metadata{Name: "title"}); s != "title" {
+		t.Errorf("expected title, actual: %s", s)
+	}
+
+	long := strings.Repeat("word ", summaryLength)
+	s := summary(core.Metadata{Description: long})
+	if n := len([]rune(s)); n > summaryLength {
+		t.Errorf("summary is too long: %d", n)
+	}
+	if !strings.HasSuffix(s, "word…") {
+		t.Errorf("summary must end with a whole word: %s", s[len(s)-20:])
+	}
+}
@@ -5,7 +5,7 @@ import (
 )
 
 const (
-	itunesHeader = `<rss version="2.0" xmlns:itunes="http://www.itunes.com/dtds/podcast-1.0.dtd" xmlns:content="http://purl.org/rss/1.0/modules/content/">` + "\n"
+	itunesHeader = `<rss version="2.0" xmlns:itunes="http://www.itunes.com/dtds/podcast-1.0.dtd" xmlns:content="http://purl.org/rss/1.0/modules/content/" xmlns:podcast="https://podcastindex.org/namespace/1.0">` + "\n"
 	itunesFooter = "\n" + `</rss>`
 )
 
@@ -43,18 +43,31 @@ type ItunesCategory struct {
 }
 
 type Item struct {
-	XMLName           xml.Name    `xml:"item"`
-	ItunesEpisodeType string      `xml:"itunes:episodeType"`
-	ItunesTitle       string      `xml:"itunes:title"`
-	Description       Description `xml:"description"`
-	Enclosure         Enclosure   `xml:"enclosure"`
-	Guid              string      `xml:"guid"`
-	PubDate           string      `xml:"pubDate"`
-	ItunesDuration    string      `xml:"-"`
-	ItunesExplicit    string      `xml:"itunes:explicit"`
-	ItunesImage       ItunesImage `xml:"itunes:image"`
-	ItunesAuthor      string      `xml:"itunes:author"`
-	ItunesSummary     Description `xml:"itunes:summary"`
+	XMLName           xml.Name     `xml:"item"`
+	ItunesEpisodeType string       `xml:"itunes:episodeType"`
+	ItunesTitle       string       `xml:"itunes:title"`
+	Description       Description  `xml:"description"`
+	Enclosure         Enclosure    `xml:"enclosure"`
+	Guid              string       `xml:"guid"`
+	PubDate           string       `xml:"pubDate"`
+	ItunesDuration    string       `xml:"itunes:duration,omitempty"`
+	ItunesExplicit    string       `xml:"itunes:explicit"`
+	ItunesImage       *ItunesImage `xml:"itunes:image,omitempty"`
+	ItunesAuthor      string       `xml:"itunes:author"`
+	ItunesSummary     string       `xml:"itunes:summary"`
+	PodcastChapters   *Chapters    `xml:"podcast:chapters,omitempty"`
+	PodcastTranscript []Transcript `xml:"podcast:transcript"`
+}
+
+type Chapters struct {
+	Url  string `xml:"url,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type Transcript struct {
+	Url  string `xml:"url,attr"`
+	Type string `xml:"type,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
 }
 
 type Description struct {
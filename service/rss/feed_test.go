@@ -48,9 +48,9 @@ func Test(t *testing.T) {
 		},
 	}
 
-	Items = items
+	f.Channel.Items = items
 
-	xml, err := ToXML()
+	xml, err := f.ToXML()
 	if err != nil {
 		fmt.Println(err)
 		return
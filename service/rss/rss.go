@@ -1,10 +1,12 @@
 package rss
 
 import (
+	"context"
 	"fmt"
 	"github.com/htim/youpod"
 	"github.com/htim/youpod/core"
 	"github.com/pkg/errors"
+	"sort"
 	"strconv"
 	"time"
 )
@@ -23,15 +25,29 @@ func NewService(rootUrl string, fileService core.MetadataRepository) core.RssSer
 }
 
 func (s *service) UserFeedUrl(user core.User) string {
-	return fmt.Sprintf("%s/feed/%s", s.rootUrl, user.Username)
+	return fmt.Sprintf("%s/feed/%s/%s", s.rootUrl, user.Username, user.Token)
 }
 
-func (s *service) UserFeed(user core.User) (string, error) {
+func (s *service) UserFeed(ctx context.Context, user core.User) (string, error) {
+	return s.Feed(ctx, user, core.Feed{
+		Name:  core.MainFeed,
+		Files: user.Files,
+	})
+}
+
+func (s *service) FeedUrl(user core.User, feed core.Feed) string {
+	if feed.Name == "" || feed.Name == core.MainFeed {
+		return s.UserFeedUrl(user)
+	}
+	return fmt.Sprintf("%s/feed/%s/%s/%s", s.rootUrl, user.Username, user.Token, feed.Name)
+}
+
+func (s *service) Feed(ctx context.Context, user core.User, f core.Feed) (string, error) {
 
 	fmm := make([]core.Metadata, 0)
 
-	for _, fid := range user.Files {
-		m, err := s.fileService.GetFileMetadata(nil, fid)
+	for _, fid := range f.Files {
+		m, err := s.fileService.GetFileMetadata(ctx, fid)
 		if err == youpod.ErrMetadataNotFound {
 			continue
 		}
@@ -41,19 +57,43 @@ func (s *service) UserFeed(user core.User) (string, error) {
 		fmm = append(fmm, m)
 	}
 
+	//newest first, files uploaded at the same day are ordered by the time they were added
+	sort.SliceStable(fmm, func(i, j int) bool {
+		pi, pj := pubDate(fmm[i]), pubDate(fmm[j])
+		if !pi.Equal(pj) {
+			return pi.After(pj)
+		}
+		return fmm[i].CreatedAt.After(fmm[j].CreatedAt)
+	})
+
+	title := f.Title
+	if title == "" {
+		title = "YouPod feed"
+	}
+
+	description := f.Description
+	if description == "" {
+		description = "YouTube videos converted into a podcasts"
+	}
+
+	artwork := f.Artwork
+	if artwork == "" {
+		artwork = s.rootUrl + "/logo.png"
+	}
+
 	feed := &Feed{
 		Channel: Channel{
-			Title:        "YouPod feed",
+			Title:        title,
 			Link:         "http://youpodbot.com",
 			Language:     "ru",
-			Description:  "YouTube videos converted into a podcasts",
+			Description:  description,
 			ItunesAuthor: "YouPod Bot",
 			ItunesCategory: ItunesCategory{
 				Text: "Technology",
 			},
 			ItunesExplicit: "no",
 			ItunesImage: ItunesImage{
-				Href: s.rootUrl + "/logo.png",
+				Href: artwork,
 			},
 			ItunesOwner: ItunesOwner{
 				ItunesName:  "YouPod bot",
@@ -66,7 +106,9 @@ func (s *service) UserFeed(user core.User) (string, error) {
 
 	for _, fm := range fmm {
 
-		fileLink := fmt.Sprintf("%s/files/%s/%s.mp3", s.rootUrl, user.Username, fm.FileID)
+		fileLink := fmt.Sprintf("%s/files/%s/%s/%s", s.rootUrl, user.Username, user.Token, fm.FileID)
+		//guid must survive token rotation, otherwise podcast apps treat every episode as a new one
+		guid := fmt.Sprintf("%s/files/%s/%s", s.rootUrl, user.Username, fm.FileID)
 		author := fm.Author
 		if author == "" {
 			author = "YouPod Bot"
@@ -77,26 +119,45 @@ func (s *service) UserFeed(user core.User) (string, error) {
 			ItunesTitle:       fm.Name,
 			Description: Description{
 				Content: Content{
-					Text: fm.Name,
+					Text: descriptionHTML(fm),
 				},
 			},
 			Enclosure: Enclosure{
 				Length: strconv.FormatInt(fm.Size, 10),
-				Type:   "audio/mp3",
-				Url:    fileLink,
+				Type:   core.ContentType(fm),
+				Url:    fileLink + "." + core.Extension(fm.ContentType),
 			},
-			Guid:           fileLink,
+			Guid:           guid,
 			ItunesExplicit: "no",
-			ItunesImage: ItunesImage{
+			PubDate:        pubDate(fm).UTC().Format(rfc2822),
+			ItunesDuration: duration(fm.Duration),
+			ItunesAuthor:   author,
+			ItunesSummary:  summary(fm),
+		}
+
+		//files without picture, e.g. uploads, get channel artwork in podcast apps
+		if fm.Picture != "" {
+			item.ItunesImage = &ItunesImage{
 				Href: fileLink + "/thumbnail.jpg",
-			},
-			PubDate:      time.Now().Format(rfc2822),
-			ItunesAuthor: author,
-			ItunesSummary: Description{
-				Content: Content{
-					Text: fm.Name,
-				},
-			},
+			}
+		}
+
+		if len(fm.Chapters) > 0 {
+			item.PodcastChapters = &Chapters{
+				Url:  fileLink + "/chapters.json",
+				Type: chaptersType,
+			}
+		}
+
+		//transcripts come from subtitles, so they are timed captions
+		for _, format := range core.TranscriptFormats {
+			if _, ok := fm.Transcripts[format]; ok {
+				item.PodcastTranscript = append(item.PodcastTranscript, Transcript{
+					Url:  fileLink + "/transcript." + format,
+					Type: core.TranscriptType(format),
+					Rel:  "captions",
+				})
+			}
 		}
 
 		items = append(items, item)
@@ -114,3 +175,19 @@ func (s *service) UserFeed(user core.User) (string, error) {
 
 	return output, nil
 }
+
+//pubDate is a stable publication date: original upload date if known, otherwise the time file was added
+func pubDate(m core.Metadata) time.Time {
+	if !m.UploadDate.IsZero() {
+		return m.UploadDate
+	}
+	return m.CreatedAt
+}
+
+//duration formats seconds as HH:MM:SS, empty if unknown
+func duration(seconds int64) string {
+	if seconds <= 0 {
+		return ""
+	}
+	return fmt.Sprintf("%02d:%02d:%02d", seconds/3600, seconds%3600/60, seconds%60)
+}
@@ -0,0 +1,234 @@
+// Package audio downloads directly linked audio files and converts them to user's format if needed.
+// Implements core.SourceExtractor, also used by page and podcast extractors
+package audio
+
+import (
+	"context"
+	"fmt"
+	"github.com/htim/youpod/core"
+	"github.com/htim/youpod/service/ffmpeg"
+	"github.com/htim/youpod/service/source/web"
+	"github.com/pkg/errors"
+	"github.com/rs/xid"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"mime"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+//maxSize protects disk from endless streams
+const maxSize = 1 << 30
+
+var extensions = map[string]bool{
+	".mp3":  true,
+	".m4a":  true,
+	".aac":  true,
+	".ogg":  true,
+	".oga":  true,
+	".opus": true,
+	".wav":  true,
+	".flac": true,
+}
+
+type Service struct {
+	web       *web.Client
+	outputDir string
+}
+
+func NewService(client *web.Client, outputDir string) (*Service, error) {
+	if err := ffmpeg.Check(); err != nil {
+		return nil, err
+	}
+
+	return &Service{
+		web:       client,
+		outputDir: outputDir,
+	}, nil
+}
+
+func (s *Service) Name() string {
+	return "audio"
+}
+
+//Handles links with audio file extension, content type of other links is probed
+func (s *Service) Handles(ctx context.Context, link string) bool {
+	u, err := url.Parse(link)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return false
+	}
+
+	if extensions[strings.ToLower(path.Ext(u.Path))] {
+		return true
+	}
+
+	p, err := s.web.Probe(ctx, link)
+	if err != nil {
+		log.WithError(err).Debugf("cannot probe %s", link)
+		return false
+	}
+
+	return IsAudio(p.ContentType)
+}
+
+func (s *Service) Download(ctx context.Context, owner core.User, link string, progress core.Progress) (core.File, error) {
+	return s.Fetch(ctx, owner, link, core.Metadata{}, progress)
+}
+
+//Fetch downloads audio file in owner's format, non empty name, author, picture and upload date of m take priority over file tags
+func (s *Service) Fetch(ctx context.Context, owner core.User, link string, m core.Metadata, progress core.Progress) (core.File, error) {
+	id := xid.New().String()
+	source := s.path(id, "download")
+	format := owner.AudioFormat()
+	target := s.path(id, format.Extension())
+
+	fetched := false
+	defer func() {
+		if !fetched {
+			s.remove(id)
+		}
+	}()
+
+	if err := s.download(ctx, link, source, progress); err != nil {
+		return core.File{}, errors.Wrap(err, "cannot download file")
+	}
+
+	p, err := ffmpeg.Probe(ctx, source)
+	if err != nil {
+		return core.File{}, errors.Wrap(err, "cannot probe file")
+	}
+
+	if !p.HasAudio() {
+		return core.File{}, errors.New("file has no audio")
+	}
+
+	if p.Matches(format) {
+		if err := os.Rename(source, target); err != nil {
+			return core.File{}, errors.Wrap(err, "cannot move file")
+		}
+	} else {
+		if err := ffmpeg.Convert(ctx, source, target, format); err != nil {
+			return core.File{}, errors.Wrap(err, "cannot transcode file")
+		}
+	}
+
+	f, err := os.Open(target)
+	if err != nil {
+		return core.File{}, errors.Wrap(err, "cannot open converted file")
+	}
+
+	fileInfo, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return core.File{}, errors.Wrap(err, "cannot get file info")
+	}
+
+	fetched = true
+
+	m.TmpFileID = id
+	m.ContentType = format.ContentType()
+	m.Size = fileInfo.Size()
+	m.CreatedAt = time.Now()
+	if m.Name == "" {
+		m.Name = title(link, p)
+	}
+	if m.Author == "" {
+		m.Author = author(link, p)
+	}
+	if m.Duration == 0 {
+		m.Duration = p.Duration()
+	}
+
+	return core.File{
+		Metadata: m,
+		Content:  f,
+	}, nil
+}
+
+func (s *Service) Cleanup(f core.File) {
+	if err := f.Content.Close(); err != nil {
+		log.WithError(err).Debug("file is already closed")
+	}
+	s.remove(f.TmpFileID)
+}
+
+//IsAudio reports whether Content-Type header value is an audio type
+func IsAudio(contentType string) bool {
+	t, _, err := mime.ParseMediaType(contentType)
+	return err == nil && strings.HasPrefix(t, "audio/")
+}
+
+func (s *Service) remove(id string) {
+	for _, ext := range []string{"download", core.Mp3, core.M4a, core.Opus} {
+		file := s.path(id, ext)
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			log.WithError(err).Debugf("cannot remove file: %s", file)
+		}
+	}
+}
+
+func (s *Service) path(id, ext string) string {
+	return fmt.Sprintf("%s/%s.%s", s.outputDir, id, ext)
+}
+
+//download reports progress of the response body, total is unknown when server does not send Content-Length
+func (s *Service) download(ctx context.Context, link string, file string, progress core.Progress) error {
+	resp, err := s.web.Get(ctx, link)
+	if err != nil {
+		return errors.Wrap(err, "cannot request file")
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			log.WithError(err).Error("cannot close file response body")
+		}
+	}()
+
+	out, err := os.Create(file)
+	if err != nil {
+		return errors.Wrapf(err, "cannot create file: %s", file)
+	}
+
+	total := resp.ContentLength
+	if total < 0 {
+		total = 0
+	}
+	body := progress.Reader(resp.Body, total)
+
+	n, err := io.Copy(out, io.LimitReader(body, maxSize+1))
+	if err != nil {
+		_ = out.Close()
+		return errors.Wrap(err, "cannot save file")
+	}
+	if n > maxSize {
+		_ = out.Close()
+		return errors.Errorf("file is larger than %d bytes", maxSize)
+	}
+
+	return out.Close()
+}
+
+func title(link string, p ffmpeg.Info) string {
+	if t := p.Tag("title"); t != "" {
+		return t
+	}
+	if u, err := url.Parse(link); err == nil {
+		name := path.Base(u.Path)
+		if name = strings.TrimSuffix(name, path.Ext(name)); name != "" && name != "." && name != "/" {
+			return name
+		}
+	}
+	return link
+}
+
+func author(link string, p ffmpeg.Info) string {
+	if a := p.Tag("artist"); a != "" {
+		return a
+	}
+	if u, err := url.Parse(link); err == nil {
+		return u.Hostname()
+	}
+	return ""
+}
@@ -0,0 +1,198 @@
+// Package page downloads audio embedded into web pages with og:audio meta tags or <audio> elements.
+// Implements core.SourceExtractor
+package page
+
+import (
+	"bytes"
+	"context"
+	"github.com/htim/youpod/core"
+	"github.com/htim/youpod/service/source/audio"
+	"github.com/htim/youpod/service/source/web"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/html"
+	"io"
+	"mime"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	//maxPageSize limits how much of a page is parsed, meta tags are at the top anyway
+	maxPageSize = 2 << 20
+
+	pageTimeout = 10 * time.Second
+)
+
+type Service struct {
+	audio *audio.Service
+	web   *web.Client
+}
+
+func NewService(audio *audio.Service, client *web.Client) *Service {
+	return &Service{
+		audio: audio,
+		web:   client,
+	}
+}
+
+func (s *Service) Name() string {
+	return "page"
+}
+
+//Handles web pages with audio, the page is parsed from the probe shared with other extractors
+func (s *Service) Handles(ctx context.Context, link string) bool {
+	u, err := url.Parse(link)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return false
+	}
+
+	probe, err := s.web.Probe(ctx, link)
+	if err != nil || probe.ContentType != "text/html" {
+		return false
+	}
+
+	p, err := parse(probe.URL, io.LimitReader(bytes.NewReader(probe.Body), maxPageSize))
+	if err != nil {
+		log.WithError(err).Debugf("cannot read page %s", link)
+		return false
+	}
+
+	return p.audio != ""
+}
+
+func (s *Service) Download(ctx context.Context, owner core.User, link string, progress core.Progress) (core.File, error) {
+	p, err := s.page(ctx, link)
+	if err != nil {
+		return core.File{}, errors.Wrap(err, "cannot read page")
+	}
+
+	if p.audio == "" {
+		return core.File{}, errors.New("page has no audio")
+	}
+
+	return s.audio.Fetch(ctx, owner, p.audio, core.Metadata{
+		Name:   p.title,
+		Author: p.siteName,
+	}, progress)
+}
+
+func (s *Service) Cleanup(f core.File) {
+	s.audio.Cleanup(f)
+}
+
+type page struct {
+	audio    string
+	title    string
+	siteName string
+}
+
+func (s *Service) page(ctx context.Context, link string) (page, error) {
+	ctx, cancel := context.WithTimeout(ctx, pageTimeout)
+	defer cancel()
+
+	resp, err := s.web.Get(ctx, link)
+	if err != nil {
+		return page{}, errors.Wrap(err, "cannot request page")
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			log.WithError(err).Error("cannot close page response body")
+		}
+	}()
+
+	if t, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type")); err != nil || t != "text/html" {
+		return page{}, errors.Errorf("not a web page: %s", resp.Header.Get("Content-Type"))
+	}
+
+	//relative links are resolved against the final url after redirects
+	return parse(resp.Request.URL, io.LimitReader(resp.Body, maxPageSize))
+}
+
+//parse prefers og:audio over <audio> elements, as the latter may be players for unrelated content
+func parse(base *url.URL, r io.Reader) (page, error) {
+	var (
+		p        page
+		docTitle string
+		element  string
+		inTitle  bool
+		inAudio  bool
+	)
+
+	z := html.NewTokenizer(r)
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			if z.Err() != io.EOF {
+				return page{}, errors.Wrap(z.Err(), "cannot parse page")
+			}
+			if p.audio == "" {
+				p.audio = element
+			}
+			if p.title == "" {
+				p.title = strings.TrimSpace(docTitle)
+			}
+			return p, nil
+		case html.TextToken:
+			if inTitle {
+				docTitle += string(z.Text())
+			}
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			switch string(name) {
+			case "title":
+				inTitle = false
+			case "audio":
+				inAudio = false
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			attrs := make(map[string]string)
+			for hasAttr {
+				var k, v []byte
+				k, v, hasAttr = z.TagAttr()
+				attrs[string(k)] = string(v)
+			}
+
+			switch string(name) {
+			case "title":
+				inTitle = tt == html.StartTagToken
+			case "meta":
+				property := attrs["property"]
+				if property == "" {
+					property = attrs["name"]
+				}
+				content := strings.TrimSpace(attrs["content"])
+				switch property {
+				case "og:audio", "og:audio:url", "og:audio:secure_url":
+					if p.audio == "" {
+						p.audio = resolve(base, content)
+					}
+				case "og:title":
+					p.title = content
+				case "og:site_name":
+					p.siteName = content
+				}
+			case "audio":
+				inAudio = tt == html.StartTagToken
+				if element == "" && attrs["src"] != "" {
+					element = resolve(base, attrs["src"])
+				}
+			case "source":
+				if inAudio && element == "" && attrs["src"] != "" {
+					element = resolve(base, attrs["src"])
+				}
+			}
+		}
+	}
+}
+
+func resolve(base *url.URL, ref string) string {
+	u, err := base.Parse(ref)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return ""
+	}
+	return u.String()
+}
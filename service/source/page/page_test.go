@@ -0,0 +1,55 @@
+package page
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	base, _ := url.Parse("https://example.org/episodes/1")
+
+	p, err := parse(base, strings.NewReader(`<html><head>
+<title>Episode 1 | Example</title>
+<meta property="og:site_name" content="Example">
+</head><body>
+<video><source src="/video.mp4"></video>
+<audio controls><source src="media/episode-1.ogg" type="audio/ogg"></audio>
+</body></html>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if p.audio != "https://example.org/episodes/media/episode-1.ogg" {
+		t.Errorf("unexpected audio: %s", p.audio)
+	}
+	if p.title != "Episode 1 | Example" {
+		t.Errorf("unexpected title: %s", p.title)
+	}
+	if p.siteName != "Example" {
+		t.Errorf("unexpected site name: %s", p.siteName)
+	}
+
+	p, err = parse(base, strings.NewReader(`<html><head>
+<meta property="og:title" content="Episode 1">
+<meta property="og:audio" content="https://cdn.example.org/1.mp3">
+</head><body><audio src="/player.mp3"></audio></body></html>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if p.audio != "https://cdn.example.org/1.mp3" {
+		t.Errorf("og:audio must take priority, got: %s", p.audio)
+	}
+	if p.title != "Episode 1" {
+		t.Errorf("unexpected title: %s", p.title)
+	}
+
+	p, err = parse(base, strings.NewReader(`<html><body>no audio here</body></html>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.audio != "" {
+		t.Errorf("unexpected audio: %s", p.audio)
+	}
+}
@@ -0,0 +1,225 @@
+// Package podcast reads podcast RSS feeds, episode links are the feed link with episode guid as fragment.
+// Implements core.SourceExtractor, core.PlaylistExtractor and core.IdentifyingExtractor
+package podcast
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"github.com/htim/youpod/core"
+	"github.com/htim/youpod/service/source/audio"
+	"github.com/htim/youpod/service/source/web"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	maxFeedSize = 10 << 20
+
+	feedTimeout = 20 * time.Second
+)
+
+type Service struct {
+	audio *audio.Service
+	web   *web.Client
+}
+
+func NewService(audio *audio.Service, client *web.Client) *Service {
+	return &Service{
+		audio: audio,
+		web:   client,
+	}
+}
+
+func (s *Service) Name() string {
+	return "podcast"
+}
+
+//Handles podcast feeds, the feed is parsed from the probe shared with other extractors
+func (s *Service) Handles(ctx context.Context, link string) bool {
+	u, err := url.Parse(link)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return false
+	}
+
+	p, err := s.web.Probe(ctx, link)
+	if err != nil || len(p.Body) == 0 {
+		return false
+	}
+
+	if _, err := decode(bytes.NewReader(p.Body)); err != nil {
+		log.WithError(err).Debugf("cannot read podcast feed %s", link)
+		return false
+	}
+	return true
+}
+
+//IsPlaylist is true for feed links, links to a single episode have a fragment
+func (s *Service) IsPlaylist(link string) bool {
+	u, err := url.Parse(link)
+	return err == nil && u.Fragment == ""
+}
+
+//ID of an episode is its link, feed links are not identified as they point to the latest episode
+func (s *Service) ID(ctx context.Context, link string) (string, error) {
+	if s.IsPlaylist(link) {
+		return "", nil
+	}
+	return link, nil
+}
+
+//Playlist lists episodes in feed order, which is newest first for virtually all podcasts
+func (s *Service) Playlist(ctx context.Context, link string) (core.Playlist, error) {
+	f, err := s.feed(ctx, link)
+	if err != nil {
+		return core.Playlist{}, err
+	}
+
+	entries := make([]core.PlaylistEntry, 0, len(f.Channel.Items))
+	for _, i := range f.Channel.Items {
+		if i.Enclosure.URL == "" {
+			continue
+		}
+		entries = append(entries, core.PlaylistEntry{
+			ID:    i.id(),
+			Title: i.Title,
+			Link:  episodeLink(link, i.id()),
+		})
+	}
+
+	return core.Playlist{
+		ID:      link,
+		Title:   f.Channel.Title,
+		Entries: entries,
+	}, nil
+}
+
+//Download fetches the episode from link fragment or the latest one
+func (s *Service) Download(ctx context.Context, owner core.User, link string, progress core.Progress) (core.File, error) {
+	f, err := s.feed(ctx, link)
+	if err != nil {
+		return core.File{}, err
+	}
+
+	u, err := url.Parse(link)
+	if err != nil {
+		return core.File{}, errors.Wrap(err, "cannot parse link")
+	}
+
+	for _, i := range f.Channel.Items {
+		if i.Enclosure.URL == "" || (u.Fragment != "" && i.id() != u.Fragment) {
+			continue
+		}
+
+		author := i.Author
+		if author == "" {
+			author = f.Channel.Author
+		}
+		if author == "" {
+			author = f.Channel.Title
+		}
+
+		return s.audio.Fetch(ctx, owner, i.Enclosure.URL, core.Metadata{
+			Name:       i.Title,
+			Author:     author,
+			UploadDate: i.pubDate(),
+		}, progress)
+	}
+
+	return core.File{}, errors.Errorf("episode is not found in feed: %s", link)
+}
+
+func (s *Service) Cleanup(f core.File) {
+	s.audio.Cleanup(f)
+}
+
+func (s *Service) feed(ctx context.Context, link string) (rss, error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return rss{}, errors.Wrap(err, "cannot parse link")
+	}
+	u.Fragment = ""
+
+	ctx, cancel := context.WithTimeout(ctx, feedTimeout)
+	defer cancel()
+
+	resp, err := s.web.Get(ctx, u.String())
+	if err != nil {
+		return rss{}, errors.Wrap(err, "cannot request feed")
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			log.WithError(err).Error("cannot close feed response body")
+		}
+	}()
+
+	return decode(io.LimitReader(resp.Body, maxFeedSize))
+}
+
+func decode(r io.Reader) (rss, error) {
+	var f rss
+	d := xml.NewDecoder(r)
+	//feeds are not always utf-8, non utf-8 ones are read as is
+	d.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+	if err := d.Decode(&f); err != nil {
+		return rss{}, errors.Wrap(err, "cannot parse feed")
+	}
+
+	if f.XMLName.Local != "rss" || f.Channel.Title == "" {
+		return rss{}, errors.New("not a podcast feed")
+	}
+
+	return f, nil
+}
+
+func episodeLink(feed string, id string) string {
+	u, err := url.Parse(feed)
+	if err != nil {
+		return feed
+	}
+	u.Fragment = id
+	return u.String()
+}
+
+type rss struct {
+	XMLName xml.Name `xml:"rss"`
+	Channel struct {
+		Title  string `xml:"title"`
+		Author string `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd author"`
+		Items  []item `xml:"item"`
+	} `xml:"channel"`
+}
+
+type item struct {
+	GUID      string `xml:"guid"`
+	Title     string `xml:"title"`
+	Author    string `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd author"`
+	PubDate   string `xml:"pubDate"`
+	Enclosure struct {
+		URL  string `xml:"url,attr"`
+		Type string `xml:"type,attr"`
+	} `xml:"enclosure"`
+}
+
+//id falls back to enclosure url, guid is optional in RSS
+func (i item) id() string {
+	if g := strings.TrimSpace(i.GUID); g != "" {
+		return g
+	}
+	return i.Enclosure.URL
+}
+
+func (i item) pubDate() time.Time {
+	for _, layout := range []string{time.RFC1123Z, time.RFC1123, "Mon, 2 Jan 2006 15:04:05 -0700", "Mon, 2 Jan 2006 15:04:05 MST"} {
+		if t, err := time.Parse(layout, strings.TrimSpace(i.PubDate)); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,116 @@
+// Package source dispatches links to extractors: YouTube, audio files, web pages and podcast feeds.
+// Implements core.SourceService
+package source
+
+import (
+	"context"
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/htim/youpod/core"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+type Registry struct {
+	extractors []core.SourceExtractor
+
+	//matched remembers extractor names for recent links, as matching may require network requests.
+	//Extractors inspecting links over network share a single probe of the link, see web.Client
+	matched *lru.Cache
+}
+
+//NewRegistry accepts extractors in priority order, catch-all ones should go last
+func NewRegistry(extractors ...core.SourceExtractor) (*Registry, error) {
+	matched, err := lru.New(1000)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot init matched links cache")
+	}
+
+	return &Registry{
+		extractors: extractors,
+		matched:    matched,
+	}, nil
+}
+
+func (r *Registry) Handles(ctx context.Context, link string) bool {
+	return r.extractor(ctx, link) != nil
+}
+
+func (r *Registry) Download(ctx context.Context, owner core.User, link string, progress core.Progress) (core.File, error) {
+	e := r.extractor(ctx, link)
+	if e == nil {
+		return core.File{}, errors.Errorf("no extractor handles link: %s", link)
+	}
+
+	log.WithField("extractor", e.Name()).Debugf("downloading %s", link)
+
+	f, err := e.Download(ctx, owner, link, progress)
+	if err != nil {
+		return core.File{}, errors.Wrapf(err, "%s extractor failed", e.Name())
+	}
+
+	f.Source = e.Name()
+	//extractors may know a canonical link, e.g. youtube.com/watch for youtu.be
+	if f.URL == "" {
+		f.URL = link
+	}
+	return f, nil
+}
+
+//Cleanup is passed to the extractor that downloaded the file
+func (r *Registry) Cleanup(f core.File) {
+	for _, e := range r.extractors {
+		if e.Name() == f.Source {
+			e.Cleanup(f)
+			return
+		}
+	}
+	log.Errorf("cannot cleanup file '%s', unknown extractor '%s'", f.TmpFileID, f.Source)
+}
+
+func (r *Registry) IsPlaylist(ctx context.Context, link string) bool {
+	p, ok := r.extractor(ctx, link).(core.PlaylistExtractor)
+	return ok && p.IsPlaylist(link)
+}
+
+func (r *Registry) Playlist(ctx context.Context, link string) (core.Playlist, error) {
+	p, ok := r.extractor(ctx, link).(core.PlaylistExtractor)
+	if !ok {
+		return core.Playlist{}, errors.Errorf("link is not a playlist: %s", link)
+	}
+	return p.Playlist(ctx, link)
+}
+
+//ID is prefixed with extractor name, as IDs are unique within a single source only
+func (r *Registry) ID(ctx context.Context, link string) (string, error) {
+	e := r.extractor(ctx, link)
+	i, ok := e.(core.IdentifyingExtractor)
+	if !ok {
+		return "", nil
+	}
+
+	id, err := i.ID(ctx, link)
+	if err != nil || id == "" {
+		return "", errors.Wrapf(err, "%s extractor cannot identify link", e.Name())
+	}
+	return e.Name() + ":" + id, nil
+}
+
+//extractor returns the first extractor handling the link or nil
+func (r *Registry) extractor(ctx context.Context, link string) core.SourceExtractor {
+	if name, ok := r.matched.Get(link); ok {
+		for _, e := range r.extractors {
+			if e.Name() == name {
+				return e
+			}
+		}
+	}
+
+	for _, e := range r.extractors {
+		if e.Handles(ctx, link) {
+			r.matched.Add(link, e.Name())
+			return e
+		}
+	}
+
+	return nil
+}
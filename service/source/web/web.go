@@ -0,0 +1,201 @@
+// Package web fetches links sent by users. Connections to loopback, private and link-local addresses are refused,
+// so links cannot be used to reach internal hosts
+package web
+
+import (
+	"context"
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	//maxProbeSize is enough for podcast feeds, other probed content is much smaller
+	maxProbeSize = 10 << 20
+
+	probeTimeout   = 20 * time.Second
+	probeTTL       = time.Minute
+	probeCacheSize = 100
+
+	responseHeaderTimeout = 10 * time.Second
+)
+
+var ErrForbiddenAddress = errors.New("address is not public")
+
+var privateNetworks = []*net.IPNet{
+	cidr("0.0.0.0/8"),
+	cidr("10.0.0.0/8"),
+	cidr("100.64.0.0/10"),
+	cidr("172.16.0.0/12"),
+	cidr("192.168.0.0/16"),
+	cidr("198.18.0.0/15"),
+	cidr("fc00::/7"),
+}
+
+//Probe is a link fetched once, so extractors can tell whether they handle it without fetching it again
+type Probe struct {
+	URL         *url.URL //final url after redirects
+	ContentType string   //media type without parameters
+	Body        []byte   //beginning of text and xml responses, empty for others
+}
+
+type probeResult struct {
+	probe Probe
+	err   error
+	at    time.Time
+}
+
+type Client struct {
+	client *http.Client
+	probes *lru.Cache
+}
+
+func NewClient() (*Client, error) {
+	return newClient(control)
+}
+
+func newClient(control func(network, address string, c syscall.RawConn) error) (*Client, error) {
+	probes, err := lru.New(probeCacheSize)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot init probe cache")
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   control,
+	}
+
+	return &Client{
+		//no proxy, it would dial internal hosts on behalf of the client
+		client: &http.Client{
+			Transport: &http.Transport{
+				DialContext:           dialer.DialContext,
+				ResponseHeaderTimeout: responseHeaderTimeout,
+				TLSHandshakeTimeout:   10 * time.Second,
+				IdleConnTimeout:       90 * time.Second,
+				MaxIdleConns:          100,
+			},
+		},
+		probes: probes,
+	}, nil
+}
+
+//Get requests http and https links and fails on statuses other than 200 OK. Body of the response must be closed
+func (c *Client) Get(ctx context.Context, link string) (*http.Response, error) {
+	u, err := url.Parse(link)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, errors.Errorf("not a web link: %s", link)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, link, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot create request")
+	}
+
+	resp, err := c.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot make request")
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		_ = resp.Body.Close()
+		return nil, errors.Errorf("unexpected response status: %d", resp.StatusCode)
+	}
+
+	return resp, nil
+}
+
+//Probe fetches the link or returns recent probe of it, failed probes are remembered too
+func (c *Client) Probe(ctx context.Context, link string) (Probe, error) {
+	//fragment is not sent to servers, links differing by it share the probe
+	if u, err := url.Parse(link); err == nil {
+		u.Fragment = ""
+		link = u.String()
+	}
+
+	if v, ok := c.probes.Get(link); ok {
+		if r := v.(probeResult); time.Since(r.at) < probeTTL {
+			return r.probe, r.err
+		}
+	}
+
+	p, err := c.probe(ctx, link)
+	//a probe interrupted by caller says nothing about the link
+	if ctx.Err() == nil {
+		c.probes.Add(link, probeResult{probe: p, err: err, at: time.Now()})
+	}
+	return p, err
+}
+
+func (c *Client) probe(ctx context.Context, link string) (Probe, error) {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+
+	resp, err := c.Get(ctx, link)
+	if err != nil {
+		return Probe{}, err
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			log.WithError(err).Error("cannot close probe response body")
+		}
+	}()
+
+	t, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	p := Probe{
+		URL:         resp.Request.URL,
+		ContentType: t,
+	}
+
+	if t == "" || strings.HasPrefix(t, "text/") || strings.HasSuffix(t, "xml") {
+		if p.Body, err = ioutil.ReadAll(io.LimitReader(resp.Body, maxProbeSize)); err != nil {
+			return Probe{}, errors.Wrap(err, "cannot read response")
+		}
+	}
+
+	return p, nil
+}
+
+//control is called with resolved address, so host names resolving to internal addresses are refused too
+func control(network, address string, c syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	if ip := net.ParseIP(host); ip == nil || !public(ip) {
+		return errors.Wrap(ErrForbiddenAddress, host)
+	}
+	return nil
+}
+
+func public(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return false
+	}
+	for _, n := range privateNetworks {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+func cidr(s string) *net.IPNet {
+	_, n, err := net.ParseCIDR(s)
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
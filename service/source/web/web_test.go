@@ -0,0 +1,84 @@
+package web
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"syscall"
+	"testing"
+)
+
+func TestPublic(t *testing.T) {
+	for ip, expected := range map[string]bool{
+		"93.184.216.34":    true,
+		"2606:2800:220::1": true,
+		"127.0.0.1":        false,
+		"::1":              false,
+		"10.1.2.3":         false,
+		"172.20.0.1":       false,
+		"192.168.1.1":      false,
+		"169.254.169.254":  false,
+		"100.64.0.1":       false,
+		"0.0.0.0":          false,
+		"fd00::1":          false,
+		"fe80::1":          false,
+		"::ffff:127.0.0.1": false,
+	} {
+		if actual := public(net.ParseIP(ip)); actual != expected {
+			t.Errorf("%s: expected public %v, actual %v", ip, expected, actual)
+		}
+	}
+}
+
+func TestInternalHostIsRefused(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+	}))
+	defer server.Close()
+
+	c, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Probe(context.Background(), server.URL); err == nil {
+		t.Error("expected local server to be refused")
+	}
+	if n := atomic.LoadInt32(&requests); n != 0 {
+		t.Errorf("expected no requests, actual %d", n)
+	}
+}
+
+func TestProbeIsShared(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+		_, _ = w.Write([]byte("<rss></rss>"))
+	}))
+	defer server.Close()
+
+	c, err := newClient(func(network, address string, c syscall.RawConn) error {
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, link := range []string{server.URL + "/feed", server.URL + "/feed#episode"} {
+		p, err := c.Probe(context.Background(), link)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.ContentType != "application/rss+xml" || string(p.Body) != "<rss></rss>" {
+			t.Errorf("unexpected probe: %s %s", p.ContentType, string(p.Body))
+		}
+	}
+
+	if n := atomic.LoadInt32(&requests); n != 1 {
+		t.Errorf("expected a single request, actual %d", n)
+	}
+}
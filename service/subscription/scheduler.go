@@ -0,0 +1,211 @@
+// Package subscription polls subscribed channels, playlists and podcast feeds and queues new uploads.
+// Implements core.SubscriptionService
+package subscription
+
+import (
+	"context"
+	"github.com/htim/youpod"
+	"github.com/htim/youpod/core"
+	"github.com/pkg/errors"
+	"github.com/rs/xid"
+	log "github.com/sirupsen/logrus"
+	"time"
+)
+
+const (
+	//playlistTimeout limits expanding of a single playlist, so a stuck one does not stop polling of others
+	playlistTimeout = 5 * time.Minute
+
+	//maxSeenIDs bounds seen ids of a subscription besides the ones still listed in its playlist
+	maxSeenIDs = 1000
+)
+
+type Scheduler struct {
+	subscriptionRepository core.SubscriptionRepository
+	userRepository         core.UserRepository
+	sourceService          core.SourceService
+	jobQueue               core.JobQueue
+
+	interval time.Duration
+}
+
+func NewScheduler(
+	subscriptionRepository core.SubscriptionRepository,
+	userRepository core.UserRepository,
+	sourceService core.SourceService,
+	jobQueue core.JobQueue,
+	interval time.Duration,
+) *Scheduler {
+	return &Scheduler{
+		subscriptionRepository: subscriptionRepository,
+		userRepository:         userRepository,
+		sourceService:          sourceService,
+		jobQueue:               jobQueue,
+		interval:               interval,
+	}
+}
+
+func (s *Scheduler) Run() {
+	go func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			s.pollAll()
+		}
+	}()
+}
+
+//Subscribe remembers current playlist entries as seen, so only uploads made after subscription are ingested
+func (s *Scheduler) Subscribe(owner core.User, chatID int64, feed string, link string) (core.Subscription, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), playlistTimeout)
+	defer cancel()
+
+	if !s.sourceService.IsPlaylist(ctx, link) {
+		return core.Subscription{}, youpod.ErrNotPlaylist
+	}
+
+	p, err := s.sourceService.Playlist(ctx, link)
+	if err != nil {
+		return core.Subscription{}, errors.Wrapf(err, "cannot expand playlist: %s", link)
+	}
+
+	seen := make([]string, 0, len(p.Entries))
+	for _, e := range p.Entries {
+		seen = append(seen, e.ID)
+	}
+
+	now := time.Now()
+
+	sub := core.Subscription{
+		ID:        xid.New().String(),
+		Username:  owner.Username,
+		ChatID:    chatID,
+		Link:      link,
+		Title:     p.Title,
+		Feed:      feed,
+		SeenIDs:   seen,
+		CreatedAt: now,
+		CheckedAt: now,
+	}
+
+	if err := s.subscriptionRepository.SaveSubscription(context.Background(), sub); err != nil {
+		return core.Subscription{}, errors.Wrapf(err, "cannot save subscription (user ID '%s')", owner.Username)
+	}
+
+	return sub, nil
+}
+
+func (s *Scheduler) Unsubscribe(owner core.User, ID string) error {
+	ss, err := s.Subscriptions(owner)
+	if err != nil {
+		return err
+	}
+
+	for _, sub := range ss {
+		if sub.ID == ID {
+			return s.subscriptionRepository.DeleteSubscription(context.Background(), ID)
+		}
+	}
+
+	return youpod.ErrSubscriptionNotFound
+}
+
+func (s *Scheduler) Subscriptions(owner core.User) ([]core.Subscription, error) {
+	ss, err := s.subscriptionRepository.FindSubscriptionsByUser(context.Background(), owner.Username)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot find subscriptions (user ID '%s')", owner.Username)
+	}
+	return ss, nil
+}
+
+func (s *Scheduler) pollAll() {
+	ss, err := s.subscriptionRepository.FindAllSubscriptions(context.Background())
+	if err != nil {
+		log.WithError(err).Error("cannot load subscriptions")
+		return
+	}
+
+	for _, sub := range ss {
+		if err := s.poll(sub); err != nil {
+			log.WithError(err).WithField("subscription", sub.ID).WithField("user", sub.Username).Error("cannot poll subscription")
+		}
+	}
+}
+
+func (s *Scheduler) poll(sub core.Subscription) error {
+	ctx, cancel := context.WithTimeout(context.Background(), playlistTimeout)
+	defer cancel()
+
+	p, err := s.sourceService.Playlist(ctx, sub.Link)
+	if err != nil {
+		return errors.Wrapf(err, "cannot expand playlist: %s", sub.Link)
+	}
+
+	//playlists are listed newest first, enqueue in upload order
+	fresh := make([]core.PlaylistEntry, 0)
+	for i := len(p.Entries) - 1; i >= 0; i-- {
+		if !sub.Seen(p.Entries[i].ID) {
+			fresh = append(fresh, p.Entries[i])
+		}
+	}
+
+	sub.CheckedAt = time.Now()
+
+	if len(fresh) > 0 {
+		log.WithField("subscription", sub.ID).Debugf("found %d new videos", len(fresh))
+
+		user, err := s.userRepository.FindUserByUsername(context.Background(), sub.Username)
+		if err != nil {
+			return errors.Wrap(err, "cannot find subscription owner")
+		}
+
+		jobs, err := s.jobQueue.EnqueuePlaylist(user, sub.ChatID, sub.Feed, core.Playlist{
+			ID:      p.ID,
+			Title:   sub.Title,
+			Entries: fresh,
+		})
+		for i := range jobs {
+			sub.SeenIDs = append(sub.SeenIDs, fresh[i].ID)
+		}
+		if err != nil {
+			if saveErr := s.updateChecked(sub, p); saveErr != nil {
+				log.WithError(saveErr).WithField("subscription", sub.ID).Error("cannot save subscription")
+			}
+			return errors.Wrap(err, "cannot enqueue new videos")
+		}
+	}
+
+	return s.updateChecked(sub, p)
+}
+
+//updateChecked does not bring back subscription removed while it was being checked
+func (s *Scheduler) updateChecked(sub core.Subscription, p core.Playlist) error {
+	sub.SeenIDs = trimSeen(sub.SeenIDs, p)
+
+	err := s.subscriptionRepository.UpdateChecked(context.Background(), sub)
+	if err == youpod.ErrSubscriptionNotFound {
+		log.WithField("subscription", sub.ID).Debug("subscription is removed while being checked")
+		return nil
+	}
+	return errors.Wrap(err, "cannot save subscription")
+}
+
+//trimSeen keeps ids still listed in the playlist, as they would be enqueued again otherwise, and the latest maxSeenIDs of the rest
+func trimSeen(seen []string, p core.Playlist) []string {
+	if len(seen) <= maxSeenIDs {
+		return seen
+	}
+
+	listed := make(map[string]bool, len(p.Entries))
+	for _, e := range p.Entries {
+		listed[e.ID] = true
+	}
+
+	trimmed := make([]string, 0, maxSeenIDs)
+	for i, id := range seen {
+		if listed[id] || i >= len(seen)-maxSeenIDs {
+			trimmed = append(trimmed, id)
+		}
+	}
+	return trimmed
+}
@@ -0,0 +1,29 @@
+package subscription
+
+import (
+	"fmt"
+	"github.com/htim/youpod/core"
+	"testing"
+)
+
+func TestTrimSeen(t *testing.T) {
+	seen := make([]string, 0, maxSeenIDs+100)
+	for i := 0; i < maxSeenIDs+100; i++ {
+		seen = append(seen, fmt.Sprintf("video-%d", i))
+	}
+
+	if trimmed := trimSeen(seen[:maxSeenIDs], core.Playlist{}); len(trimmed) != maxSeenIDs {
+		t.Errorf("expected %d ids to be kept, actual %d", maxSeenIDs, len(trimmed))
+	}
+
+	//the oldest id is still listed in the playlist, so it must not be forgotten
+	p := core.Playlist{Entries: []core.PlaylistEntry{{ID: "video-0"}, {ID: seen[len(seen)-1]}}}
+	trimmed := trimSeen(seen, p)
+
+	if len(trimmed) != maxSeenIDs+1 {
+		t.Fatalf("expected %d ids, actual %d", maxSeenIDs+1, len(trimmed))
+	}
+	if trimmed[0] != "video-0" || trimmed[1] != "video-100" || trimmed[len(trimmed)-1] != seen[len(seen)-1] {
+		t.Errorf("unexpected ids kept: %s, %s ... %s", trimmed[0], trimmed[1], trimmed[len(trimmed)-1])
+	}
+}
@@ -0,0 +1,134 @@
+// Package tagging writes file metadata and cover art into audio files.
+// Implements core.TaggingService
+package tagging
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"github.com/htim/youpod/core"
+	"github.com/htim/youpod/service/ffmpeg"
+	"github.com/pkg/errors"
+	"github.com/rs/xid"
+	log "github.com/sirupsen/logrus"
+	"io/ioutil"
+	"os"
+)
+
+type Service struct {
+	outputDir string
+}
+
+func NewService(outputDir string) (*Service, error) {
+	if err := ffmpeg.Check(); err != nil {
+		return nil, err
+	}
+	return &Service{outputDir: outputDir}, nil
+}
+
+//Tag produces a tagged copy of the file
+func (s *Service) Tag(ctx context.Context, owner core.User, feed core.Feed, f core.File) (core.File, error) {
+	id := xid.New().String()
+	ext := core.Extension(f.ContentType)
+	target := s.path(id, ext)
+
+	tagged := false
+	defer func() {
+		if !tagged {
+			s.remove(id, ext, "source", "jpg")
+		}
+	}()
+
+	source, err := ffmpeg.Input(f.Content, s.path(id, "source"))
+	if err != nil {
+		return core.File{}, err
+	}
+
+	tags := ffmpeg.Tags{
+		Title:    f.Name,
+		Artist:   f.Author,
+		Album:    album(feed),
+		Date:     f.UploadDate,
+		Comment:  f.URL,
+		Chapters: f.Chapters,
+		Duration: float64(f.Duration),
+	}
+
+	if f.Picture != "" {
+		if tags.Cover, err = s.cover(id, f.Picture); err != nil {
+			log.WithError(err).WithField("user", owner.Username).Error("cannot write cover, file is tagged without it")
+		}
+	}
+
+	if err := ffmpeg.Remux(ctx, source, target, tags); err != nil {
+		return core.File{}, errors.Wrap(err, "cannot write tags")
+	}
+
+	content, err := os.Open(target)
+	if err != nil {
+		return core.File{}, errors.Wrap(err, "cannot open tagged file")
+	}
+
+	fileInfo, err := content.Stat()
+	if err != nil {
+		_ = content.Close()
+		return core.File{}, errors.Wrap(err, "cannot get file info")
+	}
+
+	tagged = true
+	s.remove(id, "source", "jpg")
+
+	m := f.Metadata
+	m.TmpFileID = id
+	m.Size = fileInfo.Size()
+
+	return core.File{
+		Metadata:   m,
+		Content:    content,
+		Transcript: f.Transcript,
+	}, nil
+}
+
+func (s *Service) Cleanup(f core.File) {
+	if err := f.Content.Close(); err != nil {
+		log.WithError(err).Debug("file is already closed")
+	}
+	s.remove(f.TmpFileID, core.Extension(f.ContentType))
+}
+
+//cover writes base64 encoded picture from metadata, pictures are cropped jpeg thumbnails
+func (s *Service) cover(id string, picture string) (string, error) {
+	bb, err := base64.StdEncoding.DecodeString(picture)
+	if err != nil {
+		return "", errors.Wrap(err, "cannot decode picture")
+	}
+
+	path := s.path(id, "jpg")
+	if err := ioutil.WriteFile(path, bb, 0600); err != nil {
+		return "", errors.Wrapf(err, "cannot write file: %s", path)
+	}
+	return path, nil
+}
+
+func album(feed core.Feed) string {
+	if feed.Title != "" {
+		return feed.Title
+	}
+	if feed.Name != "" && feed.Name != core.MainFeed {
+		return feed.Name
+	}
+	return "YouPod feed"
+}
+
+func (s *Service) remove(id string, exts ...string) {
+	for _, ext := range exts {
+		file := s.path(id, ext)
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			log.WithError(err).Debugf("cannot remove file: %s", file)
+		}
+	}
+}
+
+func (s *Service) path(id, ext string) string {
+	return fmt.Sprintf("%s/%s.%s", s.outputDir, id, ext)
+}
@@ -0,0 +1,145 @@
+// Package transcript parses WebVTT subtitles into cues and renders them as WebVTT or SRT
+package transcript
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/htim/youpod/core"
+	"github.com/pkg/errors"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	tag    = regexp.MustCompile(`<[^>]*>`)
+	blanks = regexp.MustCompile(`\n{2,}`)
+)
+
+//ParseVTT reads cues with markup removed. YouTube automatic captions repeat the previous line
+//in every cue to make it roll, such repeats are removed
+func ParseVTT(b []byte) ([]core.Cue, error) {
+	text := strings.TrimPrefix(string(b), "\ufeff")
+	text = strings.Replace(text, "\r\n", "\n", -1)
+
+	blocks := blanks.Split(strings.TrimSpace(text), -1)
+	if len(blocks) == 0 || !strings.HasPrefix(blocks[0], "WEBVTT") {
+		return nil, errors.New("not a WebVTT file")
+	}
+
+	cues := make([]core.Cue, 0)
+	previous := make(map[string]bool)
+
+	for _, block := range blocks[1:] {
+		lines := strings.Split(block, "\n")
+
+		timing := -1
+		for i, l := range lines {
+			if strings.Contains(l, "-->") {
+				timing = i
+				break
+			}
+		}
+		//NOTE, STYLE and REGION blocks have no timing
+		if timing < 0 {
+			continue
+		}
+
+		fields := strings.Fields(lines[timing])
+		if len(fields) < 3 {
+			return nil, errors.Errorf("malformed cue timing: %s", lines[timing])
+		}
+		start, err := parseTimestamp(fields[0])
+		if err != nil {
+			return nil, err
+		}
+		end, err := parseTimestamp(fields[2])
+		if err != nil {
+			return nil, err
+		}
+
+		current := make(map[string]bool)
+		text := make([]string, 0)
+		for _, l := range lines[timing+1:] {
+			l = strings.TrimSpace(html.UnescapeString(tag.ReplaceAllString(l, "")))
+			if l == "" {
+				continue
+			}
+			current[l] = true
+			if !previous[l] {
+				text = append(text, l)
+			}
+		}
+		if len(current) > 0 {
+			previous = current
+		}
+
+		if len(text) == 0 {
+			continue
+		}
+
+		cues = append(cues, core.Cue{
+			Start: start,
+			End:   end,
+			Text:  strings.Join(text, "\n"),
+		})
+	}
+
+	return cues, nil
+}
+
+func VTT(cues []core.Cue) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("WEBVTT\n")
+	for _, c := range cues {
+		buf.WriteString(fmt.Sprintf("\n%s --> %s\n%s\n", timestamp(c.Start, "."), timestamp(c.End, "."), c.Text))
+	}
+	return buf.Bytes()
+}
+
+func SRT(cues []core.Cue) []byte {
+	var buf bytes.Buffer
+	for i, c := range cues {
+		if i > 0 {
+			buf.WriteString("\n")
+		}
+		buf.WriteString(fmt.Sprintf("%d\n%s --> %s\n%s\n", i+1, timestamp(c.Start, ","), timestamp(c.End, ","), c.Text))
+	}
+	return buf.Bytes()
+}
+
+//Render returns transcript in one of core.TranscriptFormats
+func Render(cues []core.Cue, format string) ([]byte, error) {
+	switch format {
+	case core.VTT:
+		return VTT(cues), nil
+	case core.SRT:
+		return SRT(cues), nil
+	default:
+		return nil, errors.Errorf("unknown transcript format: %s", format)
+	}
+}
+
+//parseTimestamp accepts hh:mm:ss.ttt and mm:ss.ttt
+func parseTimestamp(s string) (float64, error) {
+	parts := strings.Split(strings.Replace(s, ",", ".", 1), ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, errors.Errorf("malformed timestamp: %s", s)
+	}
+
+	var seconds float64
+	for _, p := range parts {
+		v, err := strconv.ParseFloat(p, 64)
+		if err != nil {
+			return 0, errors.Wrapf(err, "malformed timestamp: %s", s)
+		}
+		seconds = seconds*60 + v
+	}
+	return seconds, nil
+}
+
+func timestamp(seconds float64, separator string) string {
+	ms := int64(seconds*1000 + 0.5)
+	return fmt.Sprintf("%02d:%02d:%02d%s%03d", ms/3600000, ms%3600000/60000, ms%60000/1000, separator, ms%1000)
+}
@@ -0,0 +1,45 @@
+package transcript
+
+import (
+	"github.com/htim/youpod/core"
+	"testing"
+)
+
+func TestParseVTT(t *testing.T) {
+	vtt := "WEBVTT\nKind: captions\nLanguage: en\n\n" +
+		"NOTE generated\n\n" +
+		"00:00:00.500 --> 00:00:02.000 align:start position:0%\n" +
+		"hello<00:00:00.900><c> there</c>\n\n" +
+		"00:00:02.000 --> 00:00:02.010 align:start position:0%\n" +
+		"hello there\n \n\n" +
+		"00:00:02.010 --> 00:01:04.250\n" +
+		"hello there\nTom &amp; Jerry\n"
+
+	cues, err := ParseVTT([]byte(vtt))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []core.Cue{
+		{Start: 0.5, End: 2, Text: "hello there"},
+		{Start: 2.01, End: 64.25, Text: "Tom & Jerry"},
+	}
+
+	if len(cues) != len(expected) {
+		t.Fatalf("expected %d cues, got %v", len(expected), cues)
+	}
+	for i := range cues {
+		if cues[i] != expected[i] {
+			t.Errorf("expected %v, got %v", expected[i], cues[i])
+		}
+	}
+
+	srt := "1\n00:00:00,500 --> 00:00:02,000\nhello there\n\n2\n00:00:02,010 --> 00:01:04,250\nTom & Jerry\n"
+	if actual := string(SRT(cues)); actual != srt {
+		t.Errorf("unexpected srt:\n%s", actual)
+	}
+
+	if _, err := ParseVTT([]byte("1\n00:00:00,500 --> 00:00:02,000\nhello\n")); err == nil {
+		t.Error("srt must not be parsed as vtt")
+	}
+}
@@ -0,0 +1,198 @@
+// Package upload imports audio and video files sent to the bot through Telegram Bot API.
+// Implements core.UploadService
+package upload
+
+import (
+	"context"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/htim/youpod/core"
+	"github.com/htim/youpod/service/ffmpeg"
+	"github.com/pkg/errors"
+	"github.com/rs/xid"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type Service struct {
+	api       *tgbotapi.BotAPI
+	client    *http.Client
+	outputDir string
+}
+
+func NewService(telegramToken string, outputDir string) (*Service, error) {
+	if err := ffmpeg.Check(); err != nil {
+		return nil, err
+	}
+
+	client := &http.Client{}
+
+	return &Service{
+		//only file endpoints are used, so there is no need to check the token with getMe
+		api: &tgbotapi.BotAPI{
+			Token:  telegramToken,
+			Client: client,
+		},
+		client:    client,
+		outputDir: outputDir,
+	}, nil
+}
+
+func (s *Service) Import(ctx context.Context, owner core.User, u core.Upload) (core.File, error) {
+	if u.Size > core.MaxUploadSize {
+		return core.File{}, errors.Errorf("file is too large: %d bytes", u.Size)
+	}
+
+	id := xid.New().String()
+	source := s.path(id, "upload")
+	format := owner.AudioFormat()
+	target := s.path(id, format.Extension())
+
+	imported := false
+	defer func() {
+		if !imported {
+			s.remove(id)
+		}
+	}()
+
+	if err := s.download(ctx, u.TelegramFileID, source); err != nil {
+		return core.File{}, errors.Wrap(err, "cannot download file")
+	}
+
+	p, err := ffmpeg.Probe(ctx, source)
+	if err != nil {
+		return core.File{}, errors.Wrap(err, "cannot probe file")
+	}
+
+	if !p.HasAudio() {
+		return core.File{}, errors.New("file has no audio")
+	}
+
+	if p.Matches(format) {
+		if err := os.Rename(source, target); err != nil {
+			return core.File{}, errors.Wrap(err, "cannot move file")
+		}
+	} else {
+		if err := ffmpeg.Convert(ctx, source, target, format); err != nil {
+			return core.File{}, errors.Wrap(err, "cannot transcode file")
+		}
+	}
+
+	f, err := os.Open(target)
+	if err != nil {
+		return core.File{}, errors.Wrap(err, "cannot open converted file")
+	}
+
+	fileInfo, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return core.File{}, errors.Wrap(err, "cannot get file info")
+	}
+
+	imported = true
+
+	duration := u.Duration
+	if duration == 0 {
+		duration = p.Duration()
+	}
+
+	return core.File{
+		Metadata: core.Metadata{
+			TmpFileID:   id,
+			Name:        title(u, p),
+			Author:      author(owner, u, p),
+			ContentType: format.ContentType(),
+			Size:        fileInfo.Size(),
+			CreatedAt:   time.Now(),
+			Duration:    duration,
+		},
+		Content: f,
+	}, nil
+}
+
+func (s *Service) Cleanup(f core.File) {
+	if err := f.Content.Close(); err != nil {
+		log.WithError(err).Debug("file is already closed")
+	}
+	s.remove(f.TmpFileID)
+}
+
+func (s *Service) remove(id string) {
+	for _, ext := range []string{"upload", core.Mp3, core.M4a, core.Opus} {
+		path := s.path(id, ext)
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			log.WithError(err).Debugf("cannot remove file: %s", path)
+		}
+	}
+}
+
+func (s *Service) path(id, ext string) string {
+	return fmt.Sprintf("%s/%s.%s", s.outputDir, id, ext)
+}
+
+func (s *Service) download(ctx context.Context, fileID string, path string) error {
+	url, err := s.api.GetFileDirectURL(fileID)
+	if err != nil {
+		return errors.Wrap(err, "cannot get file url")
+	}
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return errors.New("cannot create file request")
+	}
+
+	resp, err := s.client.Do(req.WithContext(ctx))
+	if err != nil {
+		//url contains bot token, so it is not logged
+		return errors.New("cannot make file request")
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			log.WithError(err).Error("cannot close file response body")
+		}
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("unexpected response status: %d", resp.StatusCode)
+	}
+
+	out, err := os.Create(path)
+	if err != nil {
+		return errors.Wrapf(err, "cannot create file: %s", path)
+	}
+
+	if _, err = io.Copy(out, resp.Body); err != nil {
+		_ = out.Close()
+		return errors.Wrap(err, "cannot save file")
+	}
+
+	return out.Close()
+}
+
+func title(u core.Upload, p ffmpeg.Info) string {
+	if u.Title != "" {
+		return u.Title
+	}
+	if t := p.Tag("title"); t != "" {
+		return t
+	}
+	if u.FileName != "" {
+		return strings.TrimSuffix(u.FileName, filepath.Ext(u.FileName))
+	}
+	return "Recording " + time.Now().Format("2006-01-02 15:04")
+}
+
+func author(owner core.User, u core.Upload, p ffmpeg.Info) string {
+	if u.Author != "" {
+		return u.Author
+	}
+	if a := p.Tag("artist"); a != "" {
+		return a
+	}
+	return owner.Username
+}
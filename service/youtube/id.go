@@ -0,0 +1,66 @@
+package youtube
+
+import (
+	"bytes"
+	"context"
+	"net/url"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var videoIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{11}$`)
+
+//ID is the video ID, it is parsed from the link when possible to avoid calling youtube-dl
+func (d *Service) ID(ctx context.Context, link string) (string, error) {
+	if id := videoID(link); id != "" {
+		return id, nil
+	}
+	return d.get(ctx, link, "--get-id")
+}
+
+//get runs youtube-dl simulation printing a single field
+func (d *Service) get(ctx context.Context, link string, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.CommandContext(ctx, "youtube-dl", append([]string{"--no-playlist"}, append(args, link)...)...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", errors.Wrapf(err, "cannot identify video: %s", stderr.String())
+	}
+
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+//videoID parses video ID from watch, short, embed and youtu.be links, empty for other links
+func videoID(link string) string {
+	u, err := url.Parse(link)
+	if err != nil {
+		return ""
+	}
+
+	var id string
+	switch host := u.Hostname(); {
+	case host == "youtu.be":
+		id = strings.Trim(u.Path, "/")
+	case host == "youtube.com" || strings.HasSuffix(host, ".youtube.com"):
+		if u.Path == "/watch" {
+			id = u.Query().Get("v")
+			break
+		}
+		for _, prefix := range []string{"/shorts/", "/embed/", "/live/", "/v/"} {
+			if strings.HasPrefix(u.Path, prefix) {
+				id = strings.Trim(strings.TrimPrefix(u.Path, prefix), "/")
+			}
+		}
+	}
+
+	if !videoIDPattern.MatchString(id) {
+		return ""
+	}
+	return id
+}
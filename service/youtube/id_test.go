@@ -0,0 +1,24 @@
+package youtube
+
+import "testing"
+
+func TestVideoID(t *testing.T) {
+	cases := map[string]string{
+		"https://www.youtube.com/watch?v=dQw4w9WgXcQ":                   "dQw4w9WgXcQ",
+		"https://m.youtube.com/watch?v=dQw4w9WgXcQ&t=42s":               "dQw4w9WgXcQ",
+		"https://www.youtube.com/watch?v=dQw4w9WgXcQ&list=PL123":        "dQw4w9WgXcQ",
+		"https://youtu.be/dQw4w9WgXcQ?t=42":                             "dQw4w9WgXcQ",
+		"https://www.youtube.com/shorts/dQw4w9WgXcQ":                    "dQw4w9WgXcQ",
+		"https://www.youtube.com/embed/dQw4w9WgXcQ":                     "dQw4w9WgXcQ",
+		"https://www.youtube.com/playlist?list=PL123":                   "",
+		"https://www.youtube.com/channel/UCuAXFkgsw1L7xaCfnd5JJOw":      "",
+		"https://example.org/watch?v=dQw4w9WgXcQ":                       "",
+		"https://www.youtube.com/watch?v=dQw4w9WgXcQ%22%3E%3Cscript%3E": "",
+	}
+
+	for link, expected := range cases {
+		if actual := videoID(link); actual != expected {
+			t.Errorf("%s: expected '%s', actual '%s'", link, expected, actual)
+		}
+	}
+}
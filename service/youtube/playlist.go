@@ -0,0 +1,95 @@
+package youtube
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/htim/youpod/core"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"net/url"
+	"os/exec"
+	"strings"
+)
+
+const watchUrl = "https://www.youtube.com/watch?v="
+
+//IsPlaylist reports whether link points to youtube playlist or channel.
+//Links to a single video inside a playlist (watch?v=...&list=...) are treated as a single video
+func (d *Service) IsPlaylist(link string) bool {
+	u, err := url.Parse(link)
+	if err != nil {
+		return false
+	}
+
+	if !strings.HasSuffix(u.Hostname(), "youtube.com") {
+		return false
+	}
+
+	if u.Path == "/playlist" {
+		return u.Query().Get("list") != ""
+	}
+
+	for _, prefix := range []string{"/channel/", "/user/", "/c/", "/@"} {
+		if strings.HasPrefix(u.Path, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+//Playlist expands playlist or channel into its entries without downloading them
+func (d *Service) Playlist(ctx context.Context, link string) (core.Playlist, error) {
+
+	log.Debugf("expanding playlist %s", link)
+
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.CommandContext(ctx, "youtube-dl", "-J", "--flat-playlist", link)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return core.Playlist{}, errors.Wrapf(err, "cannot expand playlist: %s", stderr.String())
+	}
+
+	var p playlist
+	if err := json.Unmarshal(stdout.Bytes(), &p); err != nil {
+		return core.Playlist{}, errors.Wrap(err, "cannot unmarshal playlist json")
+	}
+
+	entries := make([]core.PlaylistEntry, 0, len(p.Entries))
+	for _, e := range p.Entries {
+		if e.ID == "" {
+			continue
+		}
+		link := e.URL
+		if !strings.HasPrefix(link, "http") {
+			link = watchUrl + e.ID
+		}
+		entries = append(entries, core.PlaylistEntry{
+			ID:    e.ID,
+			Title: e.Title,
+			Link:  link,
+		})
+	}
+
+	return core.Playlist{
+		ID:      p.ID,
+		Title:   p.Title,
+		Entries: entries,
+	}, nil
+}
+
+type playlist struct {
+	ID      string          `json:"id"`
+	Title   string          `json:"title"`
+	Entries []playlistEntry `json:"entries"`
+}
+
+type playlistEntry struct {
+	ID    string `json:"id"`
+	URL   string `json:"url"`
+	Title string `json:"title"`
+}
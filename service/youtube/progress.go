@@ -0,0 +1,69 @@
+package youtube
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+
+	"github.com/htim/youpod/core"
+)
+
+//progressLine is youtube-dl --newline output like '[download]  43.2% of ~58.31MiB at  1.23MiB/s ETA 00:25'
+var progressLine = regexp.MustCompile(`^\[download\]\s+([\d.]+)% of\s+~?([\d.]+)([KMG]?i?B)`)
+
+var units = map[string]float64{
+	"B":   1,
+	"KiB": 1 << 10,
+	"MiB": 1 << 20,
+	"GiB": 1 << 30,
+	"KB":  1e3,
+	"MB":  1e6,
+	"GB":  1e9,
+}
+
+//progressWriter collects youtube-dl output and reports download progress line by line
+type progressWriter struct {
+	bytes.Buffer
+	progress core.Progress
+	line     []byte
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	if w.progress != nil {
+		w.line = append(w.line, p...)
+		for {
+			i := bytes.IndexByte(w.line, '\n')
+			if i < 0 {
+				break
+			}
+			if done, total, ok := parseProgress(string(bytes.TrimSpace(w.line[:i]))); ok {
+				w.progress(done, total)
+			}
+			w.line = w.line[i+1:]
+		}
+	}
+	return w.Buffer.Write(p)
+}
+
+func parseProgress(line string) (done, total int64, ok bool) {
+	m := progressLine.FindStringSubmatch(line)
+	if m == nil {
+		return 0, 0, false
+	}
+
+	percent, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	size, err := strconv.ParseFloat(m[2], 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	unit, ok := units[m[3]]
+	if !ok {
+		return 0, 0, false
+	}
+
+	total = int64(size * unit)
+	return int64(float64(total) * percent / 100), total, true
+}
@@ -0,0 +1,42 @@
+package youtube
+
+import "testing"
+
+func TestProgressWriter(t *testing.T) {
+	var done, total []int64
+	w := progressWriter{progress: func(d, t int64) {
+		done = append(done, d)
+		total = append(total, t)
+	}}
+
+	output := "[youtube] dQw4w9WgXcQ: Downloading webpage\n" +
+		"[download] Destination: /tmp/id.webm\n" +
+		"[download]   0.0% of 4.00MiB at 55.30KiB/s ETA 01:14\n" +
+		"[download]  50.0% of ~4.00MiB at  1.23MiB/s ETA 00:01\n" +
+		"[download] 100% of 4.00MiB in 00:02\n"
+
+	//output comes in arbitrary chunks
+	for i := 0; i < len(output); i += 7 {
+		end := i + 7
+		if end > len(output) {
+			end = len(output)
+		}
+		if _, err := w.Write([]byte(output[i:end])); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected := []int64{0, 2 << 20, 4 << 20}
+	if len(done) != len(expected) {
+		t.Fatalf("expected %d reports, actual %d", len(expected), len(done))
+	}
+	for i := range expected {
+		if done[i] != expected[i] || total[i] != 4<<20 {
+			t.Errorf("report %d: expected %d of %d, actual %d of %d", i, expected[i], 4<<20, done[i], total[i])
+		}
+	}
+
+	if w.String() != output {
+		t.Errorf("output is not collected: %s", w.String())
+	}
+}
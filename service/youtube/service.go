@@ -2,16 +2,20 @@ package youtube
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"github.com/htim/youpod/core"
+	"github.com/htim/youpod/service/transcript"
 	"github.com/rs/xid"
 	"image/jpeg"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -42,17 +46,38 @@ func NewService(outputDir string) (*Service, error) {
 	}, nil
 }
 
-func (d *Service) Download(owner core.User, link string) (core.File, error) {
+func (d *Service) Download(ctx context.Context, owner core.User, link string, progress core.Progress) (core.File, error) {
 
 	id := xid.New().String()
 
+	//partial files are left by youtube-dl when it fails or is killed on cancel
+	downloaded := false
+	defer func() {
+		if !downloaded {
+			d.remove(id)
+		}
+	}()
+
 	log.Debugf("downloading %s", link)
 
-	var stdout, stderr bytes.Buffer
+	stdout := progressWriter{progress: progress}
+	var stderr bytes.Buffer
 
 	output := fmt.Sprintf("%s/%s.%%(ext)s", d.outputDir, id)
 
-	cmd := exec.Command("youtube-dl", "--extract-audio", "--audio-format", "mp3", "-o", output, "--write-info-json", link)
+	format := owner.AudioFormat()
+
+	args := []string{"--no-playlist", "--extract-audio", "--audio-format", format.Codec}
+	if format.Bitrate != 0 {
+		args = append(args, "--audio-quality", fmt.Sprintf("%dK", format.Bitrate))
+	}
+	//manual subtitles are preferred, automatic captions are used if there are none
+	if owner.TranscriptLanguage != "" {
+		args = append(args, "--write-sub", "--write-auto-sub", "--sub-lang", owner.TranscriptLanguage, "--convert-subs", "vtt")
+	}
+	args = append(args, "-o", output, "--write-info-json", "--newline", link)
+
+	cmd := exec.CommandContext(ctx, "youtube-dl", args...)
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
 
@@ -79,57 +104,147 @@ func (d *Service) Download(owner core.User, link string) (core.File, error) {
 		return core.File{}, errors.Wrap(err, "cannot unmarshal info.json file")
 	}
 
-	f, err := os.Open(fmt.Sprintf("%s/%s.mp3", d.outputDir, id))
+	f, err := os.Open(fmt.Sprintf("%s/%s.%s", d.outputDir, id, format.Extension()))
 	if err != nil {
 		return core.File{}, errors.Wrap(err, "cannot open downloaded file")
 	}
 
 	fileInfo, err := f.Stat()
 	if err != nil {
+		_ = f.Close()
 		return core.File{}, errors.Wrap(err, "cannot get file info")
 	}
 
-	picture, err := thumbnailBase64(info.Thumbnail)
+	picture, err := thumbnailBase64(ctx, info.Thumbnail)
 	if err != nil {
 		log.WithError(err).WithField("thumbnail", info.Thumbnail).Error("cannot prepare file thumbnail")
 	}
 
+	var cues []core.Cue
+	if owner.TranscriptLanguage != "" {
+		cues, err = d.subtitles(id, owner.TranscriptLanguage)
+		if err != nil {
+			log.WithError(err).WithField("link", link).Warn("file is saved without transcript")
+		}
+	}
+
+	downloaded = true
+
 	return core.File{
 		Metadata: core.Metadata{
-			TmpFileID: id,
-			Name:      info.Fulltitle,
-			Author:    info.Uploader,
-			Size:      fileInfo.Size(),
-			Picture:   picture,
-			CreatedAt: time.Now(),
+			TmpFileID:   id,
+			Name:        info.Fulltitle,
+			ContentType: format.ContentType(),
+			Author:      info.Uploader,
+			URL:         info.WebpageURL,
+			ChannelURL:  info.channelURL(),
+			Description: info.Description,
+			Size:        fileInfo.Size(),
+			Picture:     picture,
+			CreatedAt:   time.Now(),
+			UploadDate:  info.uploadDate(),
+			Duration:    int64(info.Duration),
+			Chapters:    info.chapters(),
 		},
-		Content: f,
+		Content:    f,
+		Transcript: cues,
 	}, nil
 }
 
+//subtitles reads subtitles written by youtube-dl, nil if the video has none in the language
+func (d *Service) subtitles(id, lang string) ([]core.Cue, error) {
+	vtt, err := ioutil.ReadFile(d.subtitlesPath(id, lang))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot read subtitles file")
+	}
+
+	cues, err := transcript.ParseVTT(vtt)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot parse subtitles")
+	}
+	return cues, nil
+}
+
+func (d *Service) subtitlesPath(id, lang string) string {
+	return fmt.Sprintf("%s/%s.%s.vtt", d.outputDir, id, lang)
+}
+
 func (d *Service) Cleanup(f core.File) {
 	if err := f.Content.Close(); err != nil {
 		log.WithError(err).Debug("file is already closed")
 	}
-	mp3 := fmt.Sprintf("%s/%s.%s", d.outputDir, f.TmpFileID, "mp3")
+	audio := fmt.Sprintf("%s/%s.%s", d.outputDir, f.TmpFileID, core.Extension(f.ContentType))
 	infoJson := fmt.Sprintf("%s/%s.%s", d.outputDir, f.TmpFileID, "info.json")
-	if err := os.Remove(mp3); err != nil {
-		log.WithError(err).Debugf("cannot remove file: %s", mp3)
+	if err := os.Remove(audio); err != nil {
+		log.WithError(err).Debugf("cannot remove file: %s", audio)
 	}
 	if err := os.Remove(infoJson); err != nil {
 		log.WithError(err).Debugf("cannot remove file: %s", infoJson)
 	}
+	//language is unknown here, there is at most one subtitles file per download
+	subtitles, _ := filepath.Glob(fmt.Sprintf("%s/%s.*.vtt", d.outputDir, f.TmpFileID))
+	for _, path := range subtitles {
+		if err := os.Remove(path); err != nil {
+			log.WithError(err).Debugf("cannot remove file: %s", path)
+		}
+	}
 }
 
 type info struct {
-	Fulltitle   string `json:"fulltitle"`
-	Description string `json:"description"`
-	Uploader    string `json:"uploader"`
-	Thumbnail   string `json:"thumbnail"`
+	Fulltitle   string  `json:"fulltitle"`
+	Description string  `json:"description"`
+	Uploader    string  `json:"uploader"`
+	WebpageURL  string  `json:"webpage_url"`
+	ChannelURL  string  `json:"channel_url"`
+	UploaderURL string  `json:"uploader_url"`
+	Thumbnail   string  `json:"thumbnail"`
+	UploadDate  string  `json:"upload_date"` //YYYYMMDD
+	Duration    float64 `json:"duration"`    //seconds
+	Chapters    []struct {
+		Title     string  `json:"title"`
+		StartTime float64 `json:"start_time"`
+		EndTime   float64 `json:"end_time"`
+	} `json:"chapters"`
 }
 
-func thumbnailBase64(url string) (string, error) {
-	thumbnail, err := http.Get(url)
+func (i info) chapters() []core.Chapter {
+	cc := make([]core.Chapter, 0, len(i.Chapters))
+	for _, c := range i.Chapters {
+		cc = append(cc, core.Chapter{
+			Title: c.Title,
+			Start: c.StartTime,
+			End:   c.EndTime,
+		})
+	}
+	return cc
+}
+
+//channelURL falls back to uploader url, older videos and other sites have no channel
+func (i info) channelURL() string {
+	if i.ChannelURL != "" {
+		return i.ChannelURL
+	}
+	return i.UploaderURL
+}
+
+func (i info) uploadDate() time.Time {
+	t, err := time.Parse("20060102", i.UploadDate)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+func thumbnailBase64(ctx context.Context, url string) (string, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return "", errors.Wrap(err, "cannot create request")
+	}
+
+	thumbnail, err := http.DefaultClient.Do(req.WithContext(ctx))
 	if err != nil {
 		return "", errors.Wrap(err, "cannot download")
 	}
@@ -157,3 +272,26 @@ func thumbnailBase64(url string) (string, error) {
 
 	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
 }
+
+func (d *Service) Name() string {
+	return "youtube"
+}
+
+func (d *Service) Handles(ctx context.Context, link string) bool {
+	u, err := url.Parse(link)
+	if err != nil {
+		return false
+	}
+	host := u.Hostname()
+	return host == "youtu.be" || host == "youtube.com" || strings.HasSuffix(host, ".youtube.com")
+}
+
+//remove deletes all files of the download
+func (d *Service) remove(id string) {
+	files, _ := filepath.Glob(fmt.Sprintf("%s/%s.*", d.outputDir, id))
+	for _, path := range files {
+		if err := os.Remove(path); err != nil {
+			log.WithError(err).Debugf("cannot remove file: %s", path)
+		}
+	}
+}
@@ -11,7 +11,14 @@ import (
 var (
 	userBucket  = []byte("users")
 	filesBucket = []byte("files")
-	folders     = []byte("folders")
+	//sourcesBucket maps '<username>/<source ID>' to file ID, so a user has a single file of every original
+	sourcesBucket = []byte("sources")
+	folders       = []byte("folders")
+	jobsBucket    = []byte("jobs")
+
+	subscriptionsBucket = []byte("subscriptions")
+	feedsBucket         = []byte("feeds")
+	statesBucket        = []byte("oauth_states")
 )
 
 var (
@@ -49,7 +56,12 @@ func (c *Client) Open() error {
 	topBuckets := [][]byte{
 		userBucket,
 		filesBucket,
+		sourcesBucket,
 		folders,
+		jobsBucket,
+		subscriptionsBucket,
+		feedsBucket,
+		statesBucket,
 	}
 
 	for _, b := range topBuckets {
@@ -0,0 +1,137 @@
+package bolt
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/htim/youpod"
+	"github.com/htim/youpod/core"
+	"github.com/pkg/errors"
+	bolt "go.etcd.io/bbolt"
+)
+
+type feedRepository struct {
+	client *Client
+}
+
+func NewFeedRepository(client *Client) core.FeedRepository {
+	return &feedRepository{client: client}
+}
+
+func (r *feedRepository) SaveFeed(ctx context.Context, f core.Feed) error {
+
+	if f.Username == "" || f.Name == "" {
+		return errors.New("feed username and name must be specified")
+	}
+
+	return r.client.db.Update(func(tx *bolt.Tx) error {
+		bkt := tx.Bucket(feedsBucket)
+
+		var stored core.Feed
+		err := r.client.load(bkt, feedKey(f.Username, f.Name), &stored)
+		if err != nil && err != errNoValue {
+			return errors.Wrapf(err, "failed to load feed '%s' from bucket '%s'", f.Name, string(feedsBucket))
+		}
+		if err == nil {
+			f.Files = stored.Files
+		}
+
+		if err := r.client.save(bkt, feedKey(f.Username, f.Name), f); err != nil {
+			return errors.Wrapf(err, "failed to save feed '%s' to bucket '%s'", f.Name, string(feedsBucket))
+		}
+		return nil
+	})
+}
+
+func (r *feedRepository) FindFeed(ctx context.Context, username, name string) (core.Feed, error) {
+	var f core.Feed
+
+	err := r.client.db.View(func(tx *bolt.Tx) error {
+		bkt := tx.Bucket(feedsBucket)
+		if err := r.client.load(bkt, feedKey(username, name), &f); err != nil {
+			return errors.Wrapf(err, "failed to load feed '%s' from bucket '%s'", name, string(feedsBucket))
+		}
+		return nil
+	})
+
+	if err != nil {
+		if errors.Cause(err) == errNoValue {
+			return core.Feed{}, youpod.ErrFeedNotFound
+		}
+		return core.Feed{}, err
+	}
+
+	return f, nil
+}
+
+func (r *feedRepository) FindFeedsByUser(ctx context.Context, username string) ([]core.Feed, error) {
+	ff := make([]core.Feed, 0)
+
+	err := r.client.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(feedsBucket).Cursor()
+		prefix := []byte(feedKey(username, ""))
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			var f core.Feed
+			if err := json.Unmarshal(v, &f); err != nil {
+				return errors.Wrapf(err, "failed to unmarshal feed '%s'", string(k))
+			}
+			ff = append(ff, f)
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return ff, nil
+}
+
+func (r *feedRepository) AddFileToFeed(ctx context.Context, f core.Feed, fileID string) error {
+	return r.update(f, func(stored *core.Feed) {
+		stored.Files = append(stored.Files, fileID)
+	})
+}
+
+func (r *feedRepository) RemoveFileFromFeed(ctx context.Context, f core.Feed, fileID string) error {
+	return r.update(f, func(stored *core.Feed) {
+		files := make([]string, 0, len(stored.Files))
+		for _, id := range stored.Files {
+			if id != fileID {
+				files = append(files, id)
+			}
+		}
+		stored.Files = files
+	})
+}
+
+//update reads, changes and writes the stored feed in a single transaction, so concurrent updates are not lost
+func (r *feedRepository) update(f core.Feed, change func(stored *core.Feed)) error {
+	key := feedKey(f.Username, f.Name)
+
+	err := r.client.db.Update(func(tx *bolt.Tx) error {
+		bkt := tx.Bucket(feedsBucket)
+
+		var feed core.Feed
+		if err := r.client.load(bkt, key, &feed); err != nil {
+			return errors.Wrapf(err, "failed to load feed '%s' from bucket '%s'", f.Name, string(feedsBucket))
+		}
+
+		change(&feed)
+
+		if err := r.client.save(bkt, key, feed); err != nil {
+			return errors.Wrapf(err, "failed to save feed '%s' to bucket '%s'", f.Name, string(feedsBucket))
+		}
+		return nil
+	})
+
+	if errors.Cause(err) == errNoValue {
+		return youpod.ErrFeedNotFound
+	}
+	return err
+}
+
+//feeds are keyed by '<username>/<name>', so feeds of a user can be iterated by prefix
+func feedKey(username, name string) string {
+	return username + "/" + name
+}
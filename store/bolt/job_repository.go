@@ -0,0 +1,81 @@
+package bolt
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/htim/youpod"
+	"github.com/htim/youpod/core"
+	"github.com/pkg/errors"
+	bolt "go.etcd.io/bbolt"
+)
+
+type jobRepository struct {
+	client *Client
+}
+
+func NewJobRepository(client *Client) core.JobRepository {
+	return &jobRepository{client: client}
+}
+
+func (r *jobRepository) SaveJob(ctx context.Context, j core.Job) error {
+
+	if j.ID == "" {
+		return errors.New("job ID must be specified")
+	}
+
+	return r.client.db.Update(func(tx *bolt.Tx) error {
+		bkt := tx.Bucket(jobsBucket)
+		if err := r.client.save(bkt, j.ID, j); err != nil {
+			return errors.Wrapf(err, "failed to save job '%s' to bucket '%s'", j.ID, string(jobsBucket))
+		}
+		return nil
+	})
+}
+
+func (r *jobRepository) GetJob(ctx context.Context, ID string) (core.Job, error) {
+	var j core.Job
+
+	err := r.client.db.View(func(tx *bolt.Tx) error {
+		bkt := tx.Bucket(jobsBucket)
+		if err := r.client.load(bkt, ID, &j); err != nil {
+			return errors.Wrapf(err, "failed to load job '%s' from bucket '%s'", ID, string(jobsBucket))
+		}
+		return nil
+	})
+
+	if err != nil {
+		if errors.Cause(err) == errNoValue {
+			return core.Job{}, youpod.ErrJobNotFound
+		}
+		return core.Job{}, err
+	}
+
+	return j, nil
+}
+
+func (r *jobRepository) FindJobsByState(ctx context.Context, states ...core.JobState) ([]core.Job, error) {
+	jobs := make([]core.Job, 0)
+
+	err := r.client.db.View(func(tx *bolt.Tx) error {
+		bkt := tx.Bucket(jobsBucket)
+		return bkt.ForEach(func(k, v []byte) error {
+			var j core.Job
+			if err := json.Unmarshal(v, &j); err != nil {
+				return errors.Wrapf(err, "failed to unmarshal job '%s'", string(k))
+			}
+			for _, s := range states {
+				if j.State == s {
+					jobs = append(jobs, j)
+					break
+				}
+			}
+			return nil
+		})
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return jobs, nil
+}
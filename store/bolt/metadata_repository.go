@@ -48,6 +48,17 @@ func (r *metadataRepository) SaveFileMetadata(ctx context.Context, m core.Metada
 	}
 
 	err = r.client.db.Update(func(tx *bolt.Tx) error {
+		if m.SourceID != "" {
+			sources := tx.Bucket(sourcesBucket)
+			key := []byte(sourceKey(m.Username, m.SourceID))
+			if ID := sources.Get(key); ID != nil && string(ID) != m.FileID {
+				return youpod.ErrFileExists
+			}
+			if err := sources.Put(key, []byte(m.FileID)); err != nil {
+				return errors.Wrapf(err, "failed to save key '%s' to bucket '%s'", string(key), string(sourcesBucket))
+			}
+		}
+
 		bucket := tx.Bucket(filesBucket)
 		if err = r.client.save(bucket, m.FileID, m); err != nil {
 			return errors.Wrapf(err, "failed to save key '%s' to bucket '%s'", m.FileID, string(filesBucket))
@@ -61,3 +72,54 @@ func (r *metadataRepository) SaveFileMetadata(ctx context.Context, m core.Metada
 
 	return nil
 }
+
+func (r *metadataRepository) DeleteFileMetadata(ctx context.Context, ID string) (err error) {
+	return r.client.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(filesBucket)
+		var m core.Metadata
+		if err := r.client.load(bucket, ID, &m); err != nil {
+			if errors.Cause(err) == errNoValue {
+				return youpod.ErrMetadataNotFound
+			}
+			return errors.Wrapf(err, "failed to load key '%s' from bucket '%s'", ID, string(filesBucket))
+		}
+
+		if m.SourceID != "" {
+			sources := tx.Bucket(sourcesBucket)
+			key := []byte(sourceKey(m.Username, m.SourceID))
+			if string(sources.Get(key)) == ID {
+				if err := sources.Delete(key); err != nil {
+					return errors.Wrapf(err, "failed to delete key '%s' from bucket '%s'", string(key), string(sourcesBucket))
+				}
+			}
+		}
+
+		if err := bucket.Delete([]byte(ID)); err != nil {
+			return errors.Wrapf(err, "failed to delete key '%s' from bucket '%s'", ID, string(filesBucket))
+		}
+		return nil
+	})
+}
+
+func (r *metadataRepository) FindFileMetadataBySource(ctx context.Context, username, sourceID string) (core.Metadata, error) {
+	var ID []byte
+
+	err := r.client.db.View(func(tx *bolt.Tx) error {
+		//value is only valid within transaction
+		ID = append(ID, tx.Bucket(sourcesBucket).Get([]byte(sourceKey(username, sourceID)))...)
+		return nil
+	})
+	if err != nil {
+		return core.Metadata{}, err
+	}
+
+	if len(ID) == 0 {
+		return core.Metadata{}, youpod.ErrMetadataNotFound
+	}
+
+	return r.GetFileMetadata(ctx, string(ID))
+}
+
+func sourceKey(username, sourceID string) string {
+	return username + "/" + sourceID
+}
@@ -0,0 +1,74 @@
+package bolt
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/htim/youpod"
+	"github.com/htim/youpod/core"
+	"github.com/pkg/errors"
+	bolt "go.etcd.io/bbolt"
+	"time"
+)
+
+type oauthStateRepository struct {
+	client *Client
+}
+
+func NewOAuthStateRepository(client *Client) core.OAuthStateRepository {
+	return &oauthStateRepository{client: client}
+}
+
+//SaveState also removes expired states, as bolt has no expiration of its own
+func (r *oauthStateRepository) SaveState(ctx context.Context, s core.OAuthState) error {
+
+	if s.State == "" {
+		return errors.New("state must be specified")
+	}
+
+	return r.client.db.Update(func(tx *bolt.Tx) error {
+		bkt := tx.Bucket(statesBucket)
+
+		now := time.Now()
+		expired := make([][]byte, 0)
+		if err := bkt.ForEach(func(k, v []byte) error {
+			var old core.OAuthState
+			if err := json.Unmarshal(v, &old); err != nil || now.After(old.ExpiresAt) {
+				expired = append(expired, k)
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+		for _, k := range expired {
+			if err := bkt.Delete(k); err != nil {
+				return errors.Wrapf(err, "failed to delete expired state from bucket '%s'", string(statesBucket))
+			}
+		}
+
+		if err := r.client.save(bkt, s.State, s); err != nil {
+			return errors.Wrapf(err, "failed to save state to bucket '%s'", string(statesBucket))
+		}
+		return nil
+	})
+}
+
+func (r *oauthStateRepository) TakeState(ctx context.Context, state string) (core.OAuthState, error) {
+	var s core.OAuthState
+
+	err := r.client.db.Update(func(tx *bolt.Tx) error {
+		bkt := tx.Bucket(statesBucket)
+		if err := r.client.load(bkt, state, &s); err != nil {
+			return errors.Wrapf(err, "failed to load state from bucket '%s'", string(statesBucket))
+		}
+		return bkt.Delete([]byte(state))
+	})
+
+	if err != nil {
+		if errors.Cause(err) == errNoValue {
+			return core.OAuthState{}, youpod.ErrStateNotFound
+		}
+		return core.OAuthState{}, err
+	}
+
+	return s, nil
+}
@@ -0,0 +1,104 @@
+package bolt
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/htim/youpod"
+	"github.com/htim/youpod/core"
+	"github.com/pkg/errors"
+	bolt "go.etcd.io/bbolt"
+)
+
+type subscriptionRepository struct {
+	client *Client
+}
+
+func NewSubscriptionRepository(client *Client) core.SubscriptionRepository {
+	return &subscriptionRepository{client: client}
+}
+
+func (r *subscriptionRepository) SaveSubscription(ctx context.Context, s core.Subscription) error {
+
+	if s.ID == "" {
+		return errors.New("subscription ID must be specified")
+	}
+
+	return r.client.db.Update(func(tx *bolt.Tx) error {
+		bkt := tx.Bucket(subscriptionsBucket)
+		if err := r.client.save(bkt, s.ID, s); err != nil {
+			return errors.Wrapf(err, "failed to save subscription '%s' to bucket '%s'", s.ID, string(subscriptionsBucket))
+		}
+		return nil
+	})
+}
+
+func (r *subscriptionRepository) UpdateChecked(ctx context.Context, s core.Subscription) error {
+	return r.client.db.Update(func(tx *bolt.Tx) error {
+		bkt := tx.Bucket(subscriptionsBucket)
+
+		var stored core.Subscription
+		if err := r.client.load(bkt, s.ID, &stored); err != nil {
+			if err == errNoValue {
+				return youpod.ErrSubscriptionNotFound
+			}
+			return errors.Wrapf(err, "failed to load subscription '%s' from bucket '%s'", s.ID, string(subscriptionsBucket))
+		}
+
+		stored.SeenIDs = s.SeenIDs
+		stored.CheckedAt = s.CheckedAt
+
+		if err := r.client.save(bkt, s.ID, stored); err != nil {
+			return errors.Wrapf(err, "failed to save subscription '%s' to bucket '%s'", s.ID, string(subscriptionsBucket))
+		}
+		return nil
+	})
+}
+
+func (r *subscriptionRepository) DeleteSubscription(ctx context.Context, ID string) error {
+	return r.client.db.Update(func(tx *bolt.Tx) error {
+		bkt := tx.Bucket(subscriptionsBucket)
+		if bkt.Get([]byte(ID)) == nil {
+			return youpod.ErrSubscriptionNotFound
+		}
+		if err := bkt.Delete([]byte(ID)); err != nil {
+			return errors.Wrapf(err, "failed to delete subscription '%s' from bucket '%s'", ID, string(subscriptionsBucket))
+		}
+		return nil
+	})
+}
+
+func (r *subscriptionRepository) FindSubscriptionsByUser(ctx context.Context, username string) ([]core.Subscription, error) {
+	return r.find(func(s core.Subscription) bool {
+		return s.Username == username
+	})
+}
+
+func (r *subscriptionRepository) FindAllSubscriptions(ctx context.Context) ([]core.Subscription, error) {
+	return r.find(func(s core.Subscription) bool {
+		return true
+	})
+}
+
+func (r *subscriptionRepository) find(match func(s core.Subscription) bool) ([]core.Subscription, error) {
+	ss := make([]core.Subscription, 0)
+
+	err := r.client.db.View(func(tx *bolt.Tx) error {
+		bkt := tx.Bucket(subscriptionsBucket)
+		return bkt.ForEach(func(k, v []byte) error {
+			var s core.Subscription
+			if err := json.Unmarshal(v, &s); err != nil {
+				return errors.Wrapf(err, "failed to unmarshal subscription '%s'", string(k))
+			}
+			if match(s) {
+				ss = append(ss, s)
+			}
+			return nil
+		})
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return ss, nil
+}
@@ -2,7 +2,9 @@ package bolt
 
 import (
 	"context"
+	"encoding/json"
 	"github.com/htim/youpod"
+	"github.com/htim/youpod/auth"
 	"github.com/htim/youpod/core"
 	"github.com/pkg/errors"
 	bolt "go.etcd.io/bbolt"
@@ -15,9 +17,11 @@ var (
 
 type userRepository struct {
 	client *Client
+	cipher *auth.TokenCipher
 }
 
-func NewUserRepository(client *Client) (core.UserRepository, error) {
+//NewUserRepository creates repository which encrypts oauth tokens with the cipher, tokens are kept in plain text if it is nil
+func NewUserRepository(client *Client, cipher *auth.TokenCipher) (core.UserRepository, error) {
 
 	if err := client.db.Update(func(tx *bolt.Tx) error {
 		bkt := tx.Bucket(userBucket)
@@ -31,12 +35,30 @@ func NewUserRepository(client *Client) (core.UserRepository, error) {
 
 	return &userRepository{
 		client: client,
+		cipher: cipher,
 	}, nil
 }
 
+//SaveUser creates the user or updates all its fields but files, which are changed by AddFileToUser and RemoveFileFromUser only
 func (s *userRepository) SaveUser(ctx context.Context, u core.User) error {
-	err := s.client.db.Update(func(tx *bolt.Tx) error {
+	token, err := s.cipher.Encrypt(u.GDriveToken, u.Username)
+	if err != nil {
+		return errors.Wrapf(err, "cannot encrypt token of user '%s'", u.Username)
+	}
+	u.GDriveToken = token
+
+	err = s.client.db.Update(func(tx *bolt.Tx) error {
 		bkt := tx.Bucket(userBucket)
+
+		var stored core.User
+		err := s.client.load(bkt, u.Username, &stored)
+		if err != nil && err != errNoValue {
+			return errors.Wrapf(err, "failed to load user '%s' from bucket '%s'", u.Username, string(userBucket))
+		}
+		if err == nil {
+			u.Files = stored.Files
+		}
+
 		if err := s.client.save(bkt, u.Username, u); err != nil {
 			return errors.Wrapf(err, "failed to save user '%s' in bucket '%s'", u.Username, string(userBucket))
 		}
@@ -74,7 +96,7 @@ func (s *userRepository) FindUserByUsername(ctx context.Context, username string
 		return core.User{}, err
 	}
 
-	return u, nil
+	return s.decrypt(u)
 }
 
 func (s *userRepository) FindUserByTelegramID(ctx context.Context, id int64) (core.User, error) {
@@ -105,20 +127,117 @@ func (s *userRepository) FindUserByTelegramID(ctx context.Context, id int64) (co
 		return core.User{}, err
 	}
 
-	return u, nil
+	return s.decrypt(u)
+}
+
+func (s *userRepository) UpdateToken(ctx context.Context, u core.User) error {
+	return s.update(u.Username, func(stored *core.User) {
+		stored.Token = u.Token
+	})
+}
+
+func (s *userRepository) UpdateGDriveToken(ctx context.Context, u core.User) error {
+	token, err := s.cipher.Encrypt(u.GDriveToken, u.Username)
+	if err != nil {
+		return errors.Wrapf(err, "cannot encrypt token of user '%s'", u.Username)
+	}
+	return s.update(u.Username, func(stored *core.User) {
+		stored.GDriveToken = token
+	})
+}
+
+func (s *userRepository) UpdateSettings(ctx context.Context, u core.User) error {
+	return s.update(u.Username, func(stored *core.User) {
+		stored.DefaultFeed = u.DefaultFeed
+		stored.Codec = u.Codec
+		stored.Bitrate = u.Bitrate
+		stored.Normalize = u.Normalize
+		stored.TrimSilence = u.TrimSilence
+		stored.Speed = u.Speed
+		stored.TranscriptLanguage = u.TranscriptLanguage
+	})
 }
 
 func (s *userRepository) AddFileToUser(ctx context.Context, u core.User, fileID string) error {
-	user, err := s.FindUserByUsername(ctx, u.Username)
+	return s.update(u.Username, func(stored *core.User) {
+		stored.Files = append(stored.Files, fileID)
+	})
+}
+
+func (s *userRepository) RemoveFileFromUser(ctx context.Context, u core.User, fileID string) error {
+	return s.update(u.Username, func(stored *core.User) {
+		files := make([]string, 0, len(stored.Files))
+		for _, id := range stored.Files {
+			if id != fileID {
+				files = append(files, id)
+			}
+		}
+		stored.Files = files
+	})
+}
+
+//update reads, changes and writes the stored user in a single transaction, so concurrent updates are not lost.
+//Token of the stored user stays encrypted
+func (s *userRepository) update(username string, change func(stored *core.User)) error {
+	err := s.client.db.Update(func(tx *bolt.Tx) error {
+		bkt := tx.Bucket(userBucket)
+
+		var u core.User
+		if err := s.client.load(bkt, username, &u); err != nil {
+			return errors.Wrapf(err, "failed to load user '%s' from bucket '%s'", username, string(userBucket))
+		}
+
+		change(&u)
+
+		if err := s.client.save(bkt, username, u); err != nil {
+			return errors.Wrapf(err, "failed to save user '%s' in bucket '%s'", username, string(userBucket))
+		}
+		return nil
+	})
+
+	if errors.Cause(err) == errNoValue {
+		return youpod.ErrUserNotFound
+	}
+	return err
+}
+
+func (s *userRepository) FindAllUsers(ctx context.Context) ([]core.User, error) {
+	uu := make([]core.User, 0)
+
+	err := s.client.db.View(func(tx *bolt.Tx) error {
+		bkt := tx.Bucket(userBucket)
+		return bkt.ForEach(func(k, v []byte) error {
+			//nested telegram id bucket has nil value
+			if v == nil {
+				return nil
+			}
+			var u core.User
+			if err := json.Unmarshal(v, &u); err != nil {
+				return errors.Wrapf(err, "failed to unmarshal user '%s'", string(k))
+			}
+			uu = append(uu, u)
+			return nil
+		})
+	})
+
 	if err != nil {
-		return errors.Wrap(err, "cannot find user")
+		return nil, err
 	}
-	if user.Files == nil {
-		user.Files = make([]string, 0)
+
+	for i := range uu {
+		if uu[i], err = s.decrypt(uu[i]); err != nil {
+			return nil, err
+		}
 	}
-	user.Files = append(user.Files, fileID)
-	if err = s.SaveUser(ctx, user); err != nil {
-		return errors.Wrap(err, "cannot update user")
+
+	return uu, nil
+}
+
+func (s *userRepository) decrypt(u core.User) (core.User, error) {
+	token, err := s.cipher.Decrypt(u.GDriveToken, u.Username)
+	if err != nil {
+		return core.User{}, errors.Wrapf(err, "cannot decrypt token of user '%s'", u.Username)
 	}
-	return nil
+	u.GDriveToken = token
+	return u, nil
 }
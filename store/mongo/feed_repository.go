@@ -0,0 +1,87 @@
+package mongo
+
+import (
+	"context"
+	"github.com/htim/youpod"
+	"github.com/htim/youpod/core"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type feedRepository struct {
+	client *Client
+}
+
+func NewFeedRepository(client *Client) core.FeedRepository {
+	return &feedRepository{client: client}
+}
+
+func (r *feedRepository) SaveFeed(ctx context.Context, f core.Feed) error {
+	files := f.Files
+	if files == nil {
+		files = make([]string, 0)
+	}
+
+	filter := bson.D{{"username", f.Username}, {"name", f.Name}}
+	update := bson.D{
+		{"$set", bson.D{{"title", f.Title}, {"description", f.Description}, {"artwork", f.Artwork}}},
+		{"$setOnInsert", bson.D{{"feed_id", f.ID}, {"files", files}, {"created_at", f.CreatedAt}}},
+	}
+	if _, err := r.client.db.Collection(feeds).UpdateOne(ctx, filter, update, options.Update().SetUpsert(true)); err != nil {
+		return errors.Wrap(err, "cannot save feed")
+	}
+	return nil
+}
+
+func (r *feedRepository) FindFeed(ctx context.Context, username, name string) (core.Feed, error) {
+	var f core.Feed
+
+	filter := bson.D{{"username", username}, {"name", name}}
+
+	if err := r.client.db.Collection(feeds).FindOne(ctx, filter).Decode(&f); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return core.Feed{}, youpod.ErrFeedNotFound
+		}
+		return core.Feed{}, errors.Wrap(err, "cannot find feed")
+	}
+
+	return f, nil
+}
+
+func (r *feedRepository) FindFeedsByUser(ctx context.Context, username string) ([]core.Feed, error) {
+	filter := bson.D{{"username", username}}
+
+	cur, err := r.client.db.Collection(feeds).Find(ctx, filter, options.Find().SetSort(bson.M{"name": 1}))
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot find feeds")
+	}
+
+	ff := make([]core.Feed, 0)
+	if err := cur.All(ctx, &ff); err != nil {
+		return nil, errors.Wrap(err, "cannot decode feeds")
+	}
+
+	return ff, nil
+}
+
+func (r *feedRepository) AddFileToFeed(ctx context.Context, f core.Feed, fileID string) error {
+	return r.update(ctx, f, bson.D{{"$push", bson.D{{"files", fileID}}}})
+}
+
+func (r *feedRepository) RemoveFileFromFeed(ctx context.Context, f core.Feed, fileID string) error {
+	return r.update(ctx, f, bson.D{{"$pull", bson.D{{"files", fileID}}}})
+}
+
+func (r *feedRepository) update(ctx context.Context, f core.Feed, update bson.D) error {
+	filter := bson.D{{"username", f.Username}, {"name", f.Name}}
+	res, err := r.client.db.Collection(feeds).UpdateOne(ctx, filter, update)
+	if err != nil {
+		return errors.Wrap(err, "cannot update feed")
+	}
+	if res.MatchedCount == 0 {
+		return youpod.ErrFeedNotFound
+	}
+	return nil
+}
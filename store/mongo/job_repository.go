@@ -0,0 +1,58 @@
+package mongo
+
+import (
+	"context"
+	"github.com/htim/youpod"
+	"github.com/htim/youpod/core"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type jobRepository struct {
+	client *Client
+}
+
+func NewJobRepository(client *Client) core.JobRepository {
+	return &jobRepository{client: client}
+}
+
+func (r *jobRepository) SaveJob(ctx context.Context, j core.Job) error {
+	filter := bson.D{{"job_id", j.ID}}
+	if _, err := r.client.db.Collection(jobs).ReplaceOne(ctx, filter, j, options.Replace().SetUpsert(true)); err != nil {
+		return errors.Wrap(err, "cannot save job")
+	}
+	return nil
+}
+
+func (r *jobRepository) GetJob(ctx context.Context, ID string) (core.Job, error) {
+	var j core.Job
+
+	filter := bson.D{{"job_id", ID}}
+
+	if err := r.client.db.Collection(jobs).FindOne(ctx, filter).Decode(&j); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return core.Job{}, youpod.ErrJobNotFound
+		}
+		return core.Job{}, errors.Wrap(err, "cannot find job")
+	}
+
+	return j, nil
+}
+
+func (r *jobRepository) FindJobsByState(ctx context.Context, states ...core.JobState) ([]core.Job, error) {
+	filter := bson.D{{"state", bson.D{{"$in", states}}}}
+
+	cur, err := r.client.db.Collection(jobs).Find(ctx, filter, options.Find().SetSort(bson.M{"created_at": 1}))
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot find jobs")
+	}
+
+	jj := make([]core.Job, 0)
+	if err := cur.All(ctx, &jj); err != nil {
+		return nil, errors.Wrap(err, "cannot decode jobs")
+	}
+
+	return jj, nil
+}
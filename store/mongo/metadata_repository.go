@@ -39,7 +39,37 @@ func (r *metadataRepository) GetFileMetadata(ctx context.Context, ID string) (co
 
 func (r *metadataRepository) SaveFileMetadata(ctx context.Context, m core.Metadata) (err error) {
 	if _, err := r.client.db.Collection(metadata).InsertOne(ctx, m); err != nil {
+		if isDuplicateKey(err) {
+			return youpod.ErrFileExists
+		}
 		return errors.Wrap(err, "cannot save metadata")
 	}
 	return nil
 }
+
+func (r *metadataRepository) FindFileMetadataBySource(ctx context.Context, username, sourceID string) (core.Metadata, error) {
+	var m core.Metadata
+
+	filter := bson.D{{"username", username}, {"source_id", sourceID}}
+
+	if err := r.client.db.Collection(metadata).FindOne(ctx, filter).Decode(&m); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return core.Metadata{}, youpod.ErrMetadataNotFound
+		}
+		return core.Metadata{}, errors.Wrap(err, "cannot find metadata")
+	}
+
+	return m, nil
+}
+
+func (r *metadataRepository) DeleteFileMetadata(ctx context.Context, ID string) (err error) {
+	filter := bson.D{{"file_id", ID}}
+	res, err := r.client.db.Collection(metadata).DeleteOne(ctx, filter)
+	if err != nil {
+		return errors.Wrap(err, "cannot delete metadata")
+	}
+	if res.DeletedCount == 0 {
+		return youpod.ErrMetadataNotFound
+	}
+	return nil
+}
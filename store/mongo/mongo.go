@@ -12,6 +12,11 @@ import (
 const (
 	users    = "users"
 	metadata = "metadata"
+	jobs     = "jobs"
+
+	subscriptions = "subscriptions"
+	feeds         = "feeds"
+	states        = "oauth_states"
 )
 
 type Client struct {
@@ -57,16 +62,110 @@ func (c *Client) Open() error {
 		return errors.Wrap(err, "cannot create indexes on users collection")
 	}
 
-	if _, err := c.db.Collection(metadata).Indexes().CreateOne(ctx,
-		mongo.IndexModel{
+	metadataIndexes := []mongo.IndexModel{
+		{
 			Keys: bson.M{
 				"file_id": 1,
 			},
 			Options: options.Index().SetUnique(true),
 		},
+		{
+			//a user has a single file of every original, files without source ID are not indexed
+			Keys: bson.D{
+				{"username", 1},
+				{"source_id", 1},
+			},
+			Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{
+				"source_id": bson.M{"$gt": ""},
+			}),
+		},
+	}
+
+	if _, err := c.db.Collection(metadata).Indexes().CreateMany(ctx, metadataIndexes); err != nil {
+		return errors.Wrap(err, "cannot create indexes on metadata collection")
+	}
+
+	jobsIndexes := []mongo.IndexModel{
+		{
+			Keys: bson.M{
+				"job_id": 1,
+			},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.M{
+				"state": 1,
+			},
+		},
+	}
+
+	if _, err := c.db.Collection(jobs).Indexes().CreateMany(ctx, jobsIndexes); err != nil {
+		return errors.Wrap(err, "cannot create indexes on jobs collection")
+	}
+
+	subscriptionsIndexes := []mongo.IndexModel{
+		{
+			Keys: bson.M{
+				"subscription_id": 1,
+			},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.M{
+				"username": 1,
+			},
+		},
+	}
+
+	if _, err := c.db.Collection(subscriptions).Indexes().CreateMany(ctx, subscriptionsIndexes); err != nil {
+		return errors.Wrap(err, "cannot create indexes on subscriptions collection")
+	}
+
+	if _, err := c.db.Collection(feeds).Indexes().CreateOne(ctx,
+		mongo.IndexModel{
+			Keys: bson.D{
+				{"username", 1},
+				{"name", 1},
+			},
+			Options: options.Index().SetUnique(true),
+		},
 	); err != nil {
-		return errors.Wrap(err, "cannot create index on metadata collection")
+		return errors.Wrap(err, "cannot create index on feeds collection")
+	}
+
+	statesIndexes := []mongo.IndexModel{
+		{
+			Keys: bson.M{
+				"state": 1,
+			},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			//unused states are removed by mongo after expiration
+			Keys: bson.M{
+				"expires_at": 1,
+			},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	}
+
+	if _, err := c.db.Collection(states).Indexes().CreateMany(ctx, statesIndexes); err != nil {
+		return errors.Wrap(err, "cannot create indexes on oauth states collection")
 	}
 
 	return nil
 }
+
+//isDuplicateKey reports whether write failed because of unique index
+func isDuplicateKey(err error) bool {
+	e, ok := err.(mongo.WriteException)
+	if !ok {
+		return false
+	}
+	for _, we := range e.WriteErrors {
+		if we.Code == 11000 {
+			return true
+		}
+	}
+	return false
+}
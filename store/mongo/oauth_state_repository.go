@@ -0,0 +1,40 @@
+package mongo
+
+import (
+	"context"
+	"github.com/htim/youpod"
+	"github.com/htim/youpod/core"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type oauthStateRepository struct {
+	client *Client
+}
+
+func NewOAuthStateRepository(client *Client) core.OAuthStateRepository {
+	return &oauthStateRepository{client: client}
+}
+
+func (r *oauthStateRepository) SaveState(ctx context.Context, s core.OAuthState) error {
+	if _, err := r.client.db.Collection(states).InsertOne(ctx, s); err != nil {
+		return errors.Wrap(err, "cannot save oauth state")
+	}
+	return nil
+}
+
+func (r *oauthStateRepository) TakeState(ctx context.Context, state string) (core.OAuthState, error) {
+	var s core.OAuthState
+
+	filter := bson.D{{"state", state}}
+
+	if err := r.client.db.Collection(states).FindOneAndDelete(ctx, filter).Decode(&s); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return core.OAuthState{}, youpod.ErrStateNotFound
+		}
+		return core.OAuthState{}, errors.Wrap(err, "cannot take oauth state")
+	}
+
+	return s, nil
+}
@@ -0,0 +1,73 @@
+package mongo
+
+import (
+	"context"
+	"github.com/htim/youpod"
+	"github.com/htim/youpod/core"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type subscriptionRepository struct {
+	client *Client
+}
+
+func NewSubscriptionRepository(client *Client) core.SubscriptionRepository {
+	return &subscriptionRepository{client: client}
+}
+
+func (r *subscriptionRepository) SaveSubscription(ctx context.Context, s core.Subscription) error {
+	filter := bson.D{{"subscription_id", s.ID}}
+	if _, err := r.client.db.Collection(subscriptions).ReplaceOne(ctx, filter, s, options.Replace().SetUpsert(true)); err != nil {
+		return errors.Wrap(err, "cannot save subscription")
+	}
+	return nil
+}
+
+func (r *subscriptionRepository) UpdateChecked(ctx context.Context, s core.Subscription) error {
+	filter := bson.D{{"subscription_id", s.ID}}
+	update := bson.D{{"$set", bson.D{{"seen_ids", s.SeenIDs}, {"checked_at", s.CheckedAt}}}}
+	res, err := r.client.db.Collection(subscriptions).UpdateOne(ctx, filter, update)
+	if err != nil {
+		return errors.Wrap(err, "cannot update subscription")
+	}
+	if res.MatchedCount == 0 {
+		return youpod.ErrSubscriptionNotFound
+	}
+	return nil
+}
+
+func (r *subscriptionRepository) DeleteSubscription(ctx context.Context, ID string) error {
+	filter := bson.D{{"subscription_id", ID}}
+	res, err := r.client.db.Collection(subscriptions).DeleteOne(ctx, filter)
+	if err != nil {
+		return errors.Wrap(err, "cannot delete subscription")
+	}
+	if res.DeletedCount == 0 {
+		return youpod.ErrSubscriptionNotFound
+	}
+	return nil
+}
+
+func (r *subscriptionRepository) FindSubscriptionsByUser(ctx context.Context, username string) ([]core.Subscription, error) {
+	return r.find(ctx, bson.D{{"username", username}})
+}
+
+func (r *subscriptionRepository) FindAllSubscriptions(ctx context.Context) ([]core.Subscription, error) {
+	return r.find(ctx, bson.D{})
+}
+
+func (r *subscriptionRepository) find(ctx context.Context, filter bson.D) ([]core.Subscription, error) {
+	cur, err := r.client.db.Collection(subscriptions).Find(ctx, filter, options.Find().SetSort(bson.M{"created_at": 1}))
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot find subscriptions")
+	}
+
+	ss := make([]core.Subscription, 0)
+	if err := cur.All(ctx, &ss); err != nil {
+		return nil, errors.Wrap(err, "cannot decode subscriptions")
+	}
+
+	return ss, nil
+}
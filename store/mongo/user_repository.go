@@ -3,32 +3,67 @@ package mongo
 import (
 	"context"
 	"github.com/htim/youpod"
+	"github.com/htim/youpod/auth"
 	"github.com/htim/youpod/core"
 	"github.com/pkg/errors"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type userRepository struct {
 	client *Client
+	cipher *auth.TokenCipher
 }
 
-func NewUserRepository(client *Client) core.UserRepository {
-	return &userRepository{client: client}
+//NewUserRepository creates repository which encrypts oauth tokens with the cipher, tokens are kept in plain text if it is nil
+func NewUserRepository(client *Client, cipher *auth.TokenCipher) core.UserRepository {
+	return &userRepository{client: client, cipher: cipher}
 }
 
+//SaveUser creates the user or updates all its fields but files, which are changed by AddFileToUser and RemoveFileFromUser only
 func (r *userRepository) SaveUser(ctx context.Context, u core.User) error {
+	token, err := r.cipher.Encrypt(u.GDriveToken, u.Username)
+	if err != nil {
+		return errors.Wrapf(err, "cannot encrypt token of user '%s'", u.Username)
+	}
+
+	files := u.Files
+	if files == nil {
+		files = make([]string, 0)
+	}
+
+	fields := append(bson.D{
+		{"telegram_id", u.TelegramID},
+		{"g_drive_token", token},
+		{"feed_url", u.FeedUrl},
+		{"token", u.Token},
+	}, settings(u)...)
+
 	filter := bson.D{{"username", u.Username}}
-	if _, err := r.findBy(ctx, filter); err == youpod.ErrUserNotFound {
-		if _, err := r.client.db.Collection(users).InsertOne(ctx, u); err != nil {
-			return errors.Wrap(err, "cannot save user")
-		}
-		return nil
+	update := bson.D{{"$set", fields}, {"$setOnInsert", bson.D{{"files", files}}}}
+	if _, err := r.client.db.Collection(users).UpdateOne(ctx, filter, update, options.Update().SetUpsert(true)); err != nil {
+		return errors.Wrap(err, "cannot save user")
 	}
-	r.client.db.Collection(users).FindOneAndReplace(ctx, filter, u)
 	return nil
 }
 
+func (r *userRepository) UpdateToken(ctx context.Context, u core.User) error {
+	return r.set(ctx, u, bson.D{{"token", u.Token}})
+}
+
+func (r *userRepository) UpdateGDriveToken(ctx context.Context, u core.User) error {
+	token, err := r.cipher.Encrypt(u.GDriveToken, u.Username)
+	if err != nil {
+		return errors.Wrapf(err, "cannot encrypt token of user '%s'", u.Username)
+	}
+	return r.set(ctx, u, bson.D{{"g_drive_token", token}})
+}
+
+func (r *userRepository) UpdateSettings(ctx context.Context, u core.User) error {
+	return r.set(ctx, u, settings(u))
+}
+
 func (r *userRepository) FindUserByUsername(ctx context.Context, username string) (core.User, error) {
 	filter := bson.D{{"username", username}}
 	user, err := r.findBy(ctx, filter)
@@ -48,16 +83,76 @@ func (r *userRepository) FindUserByTelegramID(ctx context.Context, id int64) (co
 }
 
 func (r *userRepository) AddFileToUser(ctx context.Context, u core.User, fileID string) error {
-	user, err := r.FindUserByUsername(ctx, u.Username)
+	filter := bson.D{{"username", u.Username}}
+	update := bson.D{{"$push", bson.D{{"files", fileID}}}}
+	res, err := r.client.db.Collection(users).UpdateOne(ctx, filter, update)
 	if err != nil {
-		return err
+		return errors.Wrap(err, "cannot update user")
+	}
+	if res.MatchedCount == 0 {
+		return youpod.ErrUserNotFound
 	}
-	user.Files = append(user.Files, fileID)
+	return nil
+}
+
+func (r *userRepository) RemoveFileFromUser(ctx context.Context, u core.User, fileID string) error {
 	filter := bson.D{{"username", u.Username}}
-	r.client.db.Collection(users).FindOneAndReplace(ctx, filter, user)
+	update := bson.D{{"$pull", bson.D{{"files", fileID}}}}
+	res, err := r.client.db.Collection(users).UpdateOne(ctx, filter, update)
+	if err != nil {
+		return errors.Wrap(err, "cannot update user")
+	}
+	if res.MatchedCount == 0 {
+		return youpod.ErrUserNotFound
+	}
 	return nil
 }
 
+func (r *userRepository) FindAllUsers(ctx context.Context) ([]core.User, error) {
+	cur, err := r.client.db.Collection(users).Find(ctx, bson.D{})
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot find users")
+	}
+
+	uu := make([]core.User, 0)
+	if err := cur.All(ctx, &uu); err != nil {
+		return nil, errors.Wrap(err, "cannot decode users")
+	}
+
+	for i := range uu {
+		if uu[i], err = r.decrypt(uu[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	return uu, nil
+}
+
+//set updates given fields only, so concurrent updates of other fields are not overwritten by a stale copy of the user
+func (r *userRepository) set(ctx context.Context, u core.User, fields bson.D) error {
+	filter := bson.D{{"username", u.Username}}
+	res, err := r.client.db.Collection(users).UpdateOne(ctx, filter, bson.D{{"$set", fields}})
+	if err != nil {
+		return errors.Wrap(err, "cannot update user")
+	}
+	if res.MatchedCount == 0 {
+		return youpod.ErrUserNotFound
+	}
+	return nil
+}
+
+func settings(u core.User) bson.D {
+	return bson.D{
+		{"default_feed", u.DefaultFeed},
+		{"codec", u.Codec},
+		{"bitrate", u.Bitrate},
+		{"normalize", u.Normalize},
+		{"trim_silence", u.TrimSilence},
+		{"speed", u.Speed},
+		{"transcript_language", u.TranscriptLanguage},
+	}
+}
+
 func (r *userRepository) findBy(ctx context.Context, filter bson.D) (core.User, error) {
 	var u core.User
 	if err := r.client.db.Collection(users).FindOne(ctx, filter).Decode(&u); err != nil {
@@ -66,5 +161,14 @@ func (r *userRepository) findBy(ctx context.Context, filter bson.D) (core.User,
 		}
 		return core.User{}, errors.Wrap(err, "cannot get user")
 	}
+	return r.decrypt(u)
+}
+
+func (r *userRepository) decrypt(u core.User) (core.User, error) {
+	token, err := r.cipher.Decrypt(u.GDriveToken, u.Username)
+	if err != nil {
+		return core.User{}, errors.Wrapf(err, "cannot decrypt token of user '%s'", u.Username)
+	}
+	u.GDriveToken = token
 	return u, nil
 }